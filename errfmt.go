@@ -11,7 +11,9 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"emperror.dev/errors"
 	log "github.com/sirupsen/logrus"
@@ -24,6 +26,8 @@ const (
 	MaximumCallerDepth = 50
 	// DisabledFieldWeight is the value for dropping the field during ordering
 	DisabledFieldWeight = -100
+	// JSONBufferPoolMaxSize is the max. capacity of pooled JSONMarshal buffers (larger ones are dropped)
+	JSONBufferPoolMaxSize = 64 * 1024
 
 	// FlagNone disables all flags of AdvancedLogger.Flags
 	FlagNone = 0
@@ -39,19 +43,35 @@ const (
 	FlagPrintStructFieldNames = 1 << 4
 	// FlagTrimJSONDquote trims the leading and trailing '"' of JSON-formatted values
 	FlagTrimJSONDquote = 1 << 5
+	// FlagNestedJSON keeps the structure of errors and details in JSON (see AdvancedJSONFormatter.DetailsKey)
+	FlagNestedJSON = 1 << 6
+	// FlagErrorFingerprint adds the error fingerprint to logrus.Field "error_fp" (see Fingerprint)
+	FlagErrorFingerprint = 1 << 7
+	// FlagErrorFingerprintLines includes line numbers in the error fingerprint (see FingerprintWithLines)
+	FlagErrorFingerprintLines = 1 << 8
+	// FlagCallStackAppOnly keeps only the call stack frames of registered application packages
+	FlagCallStackAppOnly = 1 << 9
+	// FlagCallStackSourceContext prints source lines around application frames on console and in HTTPProblem (dev only)
+	FlagCallStackSourceContext = 1 << 10
 )
 
 var (
-	skipPackageNameForCaller = make(map[string]struct{}, 1) // nolint:gochecknoglobals
-	debugTest                = true                         // nolint:gochecknoglobals
+	debugTest = true // nolint:gochecknoglobals
+
+	// jsonBufferPool is the buffer pool of JSONMarshal
+	jsonBufferPool = sync.Pool{New: func() interface{} { return &bytes.Buffer{} }} // nolint:gochecknoglobals
 )
 
-// AddSkipPackageFromStackTrace adds package name for trimming
+// AddSkipPackageFromStackTrace adds package name for trimming (see DefaultPackageTrimmer)
 func AddSkipPackageFromStackTrace(name string) {
-	skipPackageNameForCaller[name] = struct{}{}
+	DefaultPackageTrimmer().AddPrefix(name)
 }
 
-// RegisterSkipPackageFromStackTrace registers package name of given variable (from main) for trimming
+/*
+RegisterSkipPackageFromStackTrace registers the parent of the package of given variable (from main) for trimming
+	So the trimmed function names keep the last package name, for example "errfmt.GenerateDeepErrors".
+	See DefaultPackageTrimmer, which trims the main module path automatically.
+*/
 func RegisterSkipPackageFromStackTrace(v interface{}) {
 	pkgPath := reflect.TypeOf(v).PkgPath()
 	if slash := strings.LastIndex(pkgPath, "/"); slash >= 0 {
@@ -68,90 +88,80 @@ type StackTracer interface {
 type ContextLogFieldKey string
 
 /*
-ModuleCallerPrettyfier trims registered package name(s)
+ModuleCallerPrettyfier trims registered package name(s) (see DefaultPackageTrimmer)
 	Fits to TextFormatter.CallerPrettyfier
 	Similar pull request: https://github.com/sirupsen/logrus/pull/989
 */
 func ModuleCallerPrettyfier(frame *runtime.Frame) (string, string) {
-	filePath := frame.File
-	if i := strings.LastIndex(filePath, "/"); i >= 0 {
-		filePath = filePath[i+1:]
-	}
-
-	return TrimModuleNamePrefix(frame.Function), fmt.Sprintf("%s:%d", filePath, frame.Line)
+	return DefaultPackageTrimmer().CallerPrettyfier(frame)
 }
 
-// TrimModuleNamePrefix trims package name(s)
+// TrimModuleNamePrefix trims package name(s) (see DefaultPackageTrimmer)
 func TrimModuleNamePrefix(functionName string) string {
-	for prefix := range skipPackageNameForCaller {
-		prefixDot := prefix + "."
-		prefixSlash := prefix + "/"
-		if strings.HasPrefix(functionName, prefixDot) {
-			functionName = strings.TrimPrefix(functionName, prefixDot)
-			break
-		} else if strings.HasPrefix(functionName, prefixSlash) {
-			functionName = strings.TrimPrefix(functionName, prefixSlash)
-			break
-		}
-	}
-
-	return functionName
+	return DefaultPackageTrimmer().TrimFunctionName(functionName)
 }
 
-// buildCallStackLines builds a compact list of call stack lines
-func buildCallStackLines(stackTracer StackTracer) []string {
-	callStackLines := []string{}
-
-	stackTrace := stackTracer.StackTrace()
-	for _, t := range stackTrace {
-		dsFunction := dummyState{flags: map[int]bool{'+': true}}
-		t.Format(&dsFunction, 's')
-		functionName := TrimModuleNamePrefix(strings.Split(dsFunction.str.String(), "\n")[0])
-
-		dsPath := dummyState{}
-		t.Format(&dsPath, 's')
-		path := dsPath.str.String()
-
-		dsLine := dummyState{}
-		t.Format(&dsLine, 'd')
-		line := dsLine.str.String()
+// IsApplicationFunction returns true, if the function is in a registered package (see DefaultPackageTrimmer)
+func IsApplicationFunction(functionName string) bool {
+	return DefaultPackageTrimmer().IsApplicationFunction(functionName)
+}
 
-		callStackLines = append(callStackLines, fmt.Sprintf("%s() %s:%s", functionName, path, line))
+// IsFunctionInPackages returns true, if the function is in one of the packages (or in their sub-packages)
+func IsFunctionInPackages(functionName string, packages []string) bool {
+	for _, pkg := range packages {
+		if strings.HasPrefix(functionName, pkg+".") || strings.HasPrefix(functionName, pkg+"/") {
+			return true
+		}
 	}
 
-	return callStackLines
+	return false
 }
 
-/* dummyState is a dummy, in-memory fmt.State implementation
-Used for reading StackTracer items
-*/
-type dummyState struct {
-	str   strings.Builder
-	flags map[int]bool
+// CallStackFrame is a resolved call stack frame
+type CallStackFrame struct {
+	// Function is the full function name
+	Function string
+	// Path is the full path of the source file
+	Path string
+	// Line is the line number in the source file
+	Line int
+	// Elided is the number of collapsed frames, if the frame is an elided marker
+	Elided int
+	// PC is the program counter (return address) of the frame
+	PC uintptr
+	// Entry is the entry address of the function (0, if unknown), PC-Entry is the offset in a Go panic
+	Entry uintptr
 }
 
-// Write pass trough strings.Builder
-func (ds *dummyState) Write(b []byte) (n int, err error) {
-	return ds.str.Write(b)
-}
+// String returns the compact call stack line: trimmed function name, file name and line
+func (frame CallStackFrame) String() string {
+	if frame.Elided > 0 {
+		return fmt.Sprintf("... %d frames elided", frame.Elided)
+	}
 
-// Width not implemented
-func (*dummyState) Width() (wid int, ok bool) {
-	return 0, false
+	return DefaultPackageTrimmer().FrameString(frame)
 }
 
-// Precision not implemented
-func (*dummyState) Precision() (prec int, ok bool) {
-	return 0, false
+// buildCallStackFrames resolves the frames of the call stack (see FrameCache)
+func buildCallStackFrames(stackTracer StackTracer) []CallStackFrame {
+	stackTrace := stackTracer.StackTrace()
+	callStackFrames := make([]CallStackFrame, 0, len(stackTrace))
+	for _, t := range stackTrace {
+		callStackFrames = append(callStackFrames, defaultFrameCache.Resolve(uintptr(t)))
+	}
+
+	return callStackFrames
 }
 
-// Flag returns dummyState.flags
-func (ds *dummyState) Flag(c int) bool {
-	if f, ok := ds.flags[c]; ok {
-		return f
+// buildCallStackLines builds a compact list of call stack lines
+func buildCallStackLines(callStackFrames []CallStackFrame, trimmer *PackageTrimmer) []string {
+	callStackLines := make([]string, 0, len(callStackFrames))
+
+	for _, frame := range callStackFrames {
+		callStackLines = append(callStackLines, trimmer.FrameString(frame))
 	}
 
-	return false
+	return callStackLines
 }
 
 // FunctionName returns the actual function name (long)
@@ -195,7 +205,12 @@ JSONMarshal marshals with escapeHTML flag.
 	Removes last endline.
 */
 func JSONMarshal(t interface{}, indent string, escapeHTML bool) ([]byte, error) {
-	buffer := &bytes.Buffer{}
+	if jsonBytes, ok := appendJSONScalar(nil, t, escapeHTML); ok {
+		return jsonBytes, nil
+	}
+
+	buffer := jsonBufferPool.Get().(*bytes.Buffer) // nolint:forcetypeassert
+	defer putJSONBuffer(buffer)
 	encoder := json.NewEncoder(buffer)
 	if len(indent) > 0 {
 		encoder.SetIndent("", indent)
@@ -206,7 +221,44 @@ func JSONMarshal(t interface{}, indent string, escapeHTML bool) ([]byte, error)
 	if len(jsonBytes) > 0 && jsonBytes[len(jsonBytes)-1] == '\n' {
 		jsonBytes = jsonBytes[0 : len(jsonBytes)-1]
 	}
-	return jsonBytes, err
+	return append(make([]byte, 0, len(jsonBytes)), jsonBytes...), err
+}
+
+// putJSONBuffer puts back the buffer to jsonBufferPool (large buffers are dropped)
+func putJSONBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() <= JSONBufferPoolMaxSize {
+		buffer.Reset()
+		jsonBufferPool.Put(buffer)
+	}
+}
+
+/*
+appendJSONScalar appends the JSON encoding of simple strings, booleans and integers, without json.Encoder
+	Returns false, if the value needs json.Encoder (other types, strings with escaped characters)
+*/
+func appendJSONScalar(dst []byte, value interface{}, escapeHTML bool) ([]byte, bool) {
+	switch value := value.(type) {
+	case string:
+		for i := 0; i < len(value); i++ {
+			if c := value[i]; c < 0x20 || c >= utf8.RuneSelf || c == '"' || c == '\\' ||
+				(escapeHTML && (c == '<' || c == '>' || c == '&')) {
+				return dst, false
+			}
+		}
+		dst = append(dst, '"')
+		dst = append(dst, value...)
+		return append(dst, '"'), true
+	case bool:
+		return strconv.AppendBool(dst, value), true
+	case int:
+		return strconv.AppendInt(dst, int64(value), 10), true
+	case int64:
+		return strconv.AppendInt(dst, value, 10), true
+	case uint64:
+		return strconv.AppendUint(dst, value, 10), true
+	}
+
+	return dst, false
 }
 
 // nolint:golint
@@ -2,6 +2,7 @@ package errfmt
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"testing"
@@ -87,3 +88,21 @@ func TestSortingFuncDecorator(t *testing.T) {
 		testSortingFuncDecorator(t, test.fieldOrder, test.expected, test.items)
 	}
 }
+
+func TestJSONMarshal_Scalars(t *testing.T) {
+	for _, value := range []interface{}{
+		"plain", "", "quote\"", "back\\slash", "new\nline", "<html>&", "ünicode", "\u2028",
+		true, false, 0, -42, int64(1) << 62, uint64(1) << 63,
+	} {
+		for _, escapeHTML := range []bool{false, true} {
+			buffer := &bytes.Buffer{}
+			encoder := json.NewEncoder(buffer)
+			encoder.SetEscapeHTML(escapeHTML)
+			assert.Nil(t, encoder.Encode(value))
+
+			jsonBytes, err := JSONMarshal(value, "", escapeHTML)
+			assert.Nil(t, err)
+			assert.Equal(t, bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), jsonBytes, fmt.Sprintf("%#v", value))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package errfmt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+const (
+	// KeyErrorFingerprint is the field name of the error fingerprint (see FlagErrorFingerprint)
+	KeyErrorFingerprint = "error_fp"
+	// FingerprintLength is the length of the fingerprint (hex digits)
+	FingerprintLength = 16
+)
+
+// fingerprintVariables matches the variable parts of error messages: quoted strings, UUIDs, hex and decimal numbers
+var fingerprintVariables = regexp.MustCompile( // nolint:gochecknoglobals
+	`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` +
+		`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|` +
+		`0[xX][0-9a-fA-F]+|[0-9]+(?:\.[0-9]+)?`)
+
+/*
+Fingerprint computes a stable grouping key of the error, ignoring line numbers
+	Built from the root cause type, the message templates of the error chain
+	(see FingerprintTemplate) and the application frames of the call stack
+	(all frames, if no application package is registered)
+*/
+func Fingerprint(err error) string {
+	return buildFingerprint(err, false, DefaultPackageTrimmer())
+}
+
+// FingerprintWithLines computes the fingerprint like Fingerprint, including line numbers of frames
+func FingerprintWithLines(err error) string {
+	return buildFingerprint(err, true, DefaultPackageTrimmer())
+}
+
+// FingerprintTemplate strips the variable parts (quoted strings, UUIDs and numbers) of an error message
+func FingerprintTemplate(message string) string {
+	return fingerprintVariables.ReplaceAllStringFunc(message, func(variable string) string {
+		switch variable[0] {
+		case '"', '\'':
+			return variable[:1] + "*" + variable[:1]
+		}
+		return "#"
+	})
+}
+
+// GetFingerprint computes the fingerprint of the error by Flags (see FlagErrorFingerprintLines)
+func (f *AdvancedFormatter) GetFingerprint(err error) string {
+	return buildFingerprint(err, (f.Flags&FlagErrorFingerprintLines) > 0, f.GetPackageTrimmer())
+}
+
+func buildFingerprint(err error, withLines bool, trimmer *PackageTrimmer) string {
+	if err == nil {
+		return ""
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n", ErrorType(err))
+	for _, template := range errorMessageTemplates(err) {
+		fmt.Fprintf(hash, "%s\n", template)
+	}
+
+	var stackTracer StackTracer
+	if errors.As(err, &stackTracer) {
+		callStackFrames := buildCallStackFrames(stackTracer)
+		applicationFrames := []CallStackFrame{}
+		for _, frame := range callStackFrames {
+			if trimmer.IsApplicationFunction(frame.Function) {
+				applicationFrames = append(applicationFrames, frame)
+			}
+		}
+		if len(applicationFrames) == 0 {
+			applicationFrames = callStackFrames
+		}
+
+		for _, frame := range applicationFrames {
+			hash.Write([]byte(frame.Function)) // nolint:errcheck,gosec
+			if withLines {
+				hash.Write([]byte(":" + strconv.Itoa(frame.Line))) // nolint:errcheck,gosec
+			}
+			hash.Write([]byte("\n")) // nolint:errcheck,gosec
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:FingerprintLength]
+}
+
+// errorMessageTemplates returns the own message templates of the error chain (wrap messages and the root cause)
+func errorMessageTemplates(err error) []string {
+	templates := []string{}
+	for ; err != nil; err = errors.Unwrap(err) {
+		message := err.Error()
+		if next := errors.Unwrap(err); next != nil {
+			message = strings.TrimSuffix(strings.TrimSuffix(message, next.Error()), ": ")
+		}
+		if message != "" {
+			templates = append(templates, FingerprintTemplate(message))
+		}
+	}
+
+	return templates
+}
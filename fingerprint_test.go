@@ -0,0 +1,80 @@
+package errfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newFingerprintError(id int, name string) error {
+	err := errors.NewWithDetails("user "+name+" not found", "id", id)
+	return errors.WrapIf(err, fmt.Sprintf("cannot load user %d", id))
+}
+
+func newFingerprintErrorOther(id int, name string) error {
+	err := errors.NewWithDetails("user "+name+" not found", "id", id)
+	return errors.WrapIf(err, fmt.Sprintf("cannot load user %d", id))
+}
+
+func TestFingerprint(t *testing.T) {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	fp1 := Fingerprint(newFingerprintError(1, `"alice"`))
+	fp2 := Fingerprint(newFingerprintError(2, `"bob"`))
+	assert.Len(t, fp1, FingerprintLength)
+	assert.Equal(t, fp1, fp2, "variable parts are stripped")
+	assert.NotEqual(t, fp1, Fingerprint(newFingerprintErrorOther(1, `"alice"`)), "other call stack")
+	assert.NotEqual(t, fp1, Fingerprint(errors.New("other")), "other message")
+	assert.Equal(t, "", Fingerprint(nil))
+
+	errs := []error{}
+	for i := 0; i < 2; i++ {
+		errs = append(errs, newFingerprintError(i, "x"))
+	}
+	errs = append(errs, newFingerprintError(3, "x"))
+	assert.Equal(t, Fingerprint(errs[0]), Fingerprint(errs[2]))
+	assert.Equal(t, FingerprintWithLines(errs[0]), FingerprintWithLines(errs[1]), "same line")
+	assert.NotEqual(t, FingerprintWithLines(errs[0]), FingerprintWithLines(errs[2]), "other line")
+}
+
+func TestFingerprintTemplate(t *testing.T) {
+	assert.Equal(t, `cannot open "*" (attempt #, id #): code #`,
+		FingerprintTemplate(`cannot open "/tmp/a b" (attempt 3, id 123e4567-e89b-12d3-a456-426614174000): code 0x1F`))
+	assert.Equal(t, `value '*' is #`, FingerprintTemplate(`value 'x\'y' is 1.5`))
+}
+
+func TestFingerprint_Fields(t *testing.T) {
+	loggerMock := newJSONLoggerMock(FlagExtractDetails|FlagErrorFingerprint, 0)
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).Error("USER MSG")
+
+	message := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(loggerMock.outBuf.Bytes(), &message))
+	assert.Equal(t, Fingerprint(err), message[KeyErrorFingerprint])
+
+	loggerMock = newJSONLoggerMock(FlagErrorFingerprint|FlagNestedJSON, 0)
+	loggerMock.WithError(err).Error("USER MSG")
+	message = map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(loggerMock.outBuf.Bytes(), &message))
+	assert.Equal(t, Fingerprint(err), message[KeyErrorFingerprint])
+
+	httpProblem := BuildHTTPProblem(http.StatusInternalServerError, loggerMock.WithError(err))
+	assert.Equal(t, `"`+Fingerprint(err)+`"`, httpProblem.Details[KeyErrorFingerprint])
+
+	logger := NewLogfmtLogger(log.InfoLevel, FlagErrorFingerprint|FlagErrorFingerprintLines, 0)
+	buf := new(bytes.Buffer)
+	logger.Out = buf
+	logger.WithError(err).Error("USER MSG")
+	assert.Contains(t, buf.String(), " "+KeyErrorFingerprint+"="+FingerprintWithLines(err))
+
+	loggerMock = newJSONLoggerMock(FlagErrorFingerprint, 0)
+	loggerMock.Info("USER MSG")
+	assert.NotContains(t, loggerMock.outBuf.String(), KeyErrorFingerprint, "no error")
+}
@@ -3,6 +3,7 @@ package errfmt
 import (
 	"fmt"
 	"reflect"
+	"runtime"
 	"strings"
 
 	"emperror.dev/errors"
@@ -17,6 +18,23 @@ type AdvancedFormatter struct {
 	Flags int
 	// CallStackSkipLast skips the last lines
 	CallStackSkipLast int
+	// CallStackSkipFirst skips the first lines
+	CallStackSkipFirst int
+	// CallStackDropPackages drops the frames of the packages (for example "runtime", "testing")
+	CallStackDropPackages []string
+	// CallStackCollapsePackages collapses consecutive frames of the packages (for example "net/http")
+	CallStackCollapsePackages []string
+	// CallStackRenderer renders the call stack lines, CompactCallStackRenderer if nil
+	CallStackRenderer *CallStackRenderer
+	// SourceURLTemplate is the URL template of frames, for example
+	// "https://github.com/{module}/blob/{version}/{path}#L{line}" (see ResolveSourceURL)
+	SourceURLTemplate string
+	// SourceContextSize is the number of source lines around frames (see FlagCallStackSourceContext)
+	SourceContextSize int
+	// PackageTrimmer is the trim configuration of function names and file paths, DefaultPackageTrimmer if nil
+	PackageTrimmer *PackageTrimmer
+	// StackRegistry prints repeated call stacks by ID only, if set (see KeyStackID)
+	StackRegistry *StackRegistry
 }
 
 // GetError extracts error from entry.Data (a result of log.WithError())
@@ -39,14 +57,14 @@ func (f *AdvancedFormatter) PrepareFields(entry *log.Entry, fixedFields []string
 	}
 
 	callStackLines := f.GetCallStack(entry)
-	if (f.Flags & FlagCallStackInFields) > 0 {
+	if (f.Flags&FlagCallStackInFields) > 0 && !f.IsStackSuppressed(entry) {
 		data[KeyCallStack] = callStackLines
 	}
 
 	f.RenderFieldValues(data)
 
 	if entry.HasCaller() {
-		funcVal, fileVal := ModuleCallerPrettyfier(entry.Caller)
+		funcVal, fileVal := f.TrimCaller(entry.Caller)
 		data[log.FieldKeyFunc] = funcVal
 		data[log.FieldKeyFile] = fileVal
 	}
@@ -60,35 +78,209 @@ func (f *AdvancedFormatter) PrepareFields(entry *log.Entry, fixedFields []string
 // MergeDetailsToFields merges Details from error to, if enabled
 // Always returns a new instance (copy+merge)
 func (f *AdvancedFormatter) MergeDetailsToFields(entry *log.Entry) log.Fields {
-	if (f.Flags & FlagExtractDetails) > 0 {
-		if err := f.GetError(entry); err != nil {
-			// entry.With* does not copy Level, Caller, Message, Buffer
-			return entry.WithFields(log.Fields(keyval.ToMap(errors.GetDetails(err)))).Data
+	var data log.Fields
+	err := f.GetError(entry)
+	if (f.Flags&FlagExtractDetails) > 0 && err != nil {
+		// entry.With* does not copy Level, Caller, Message, Buffer
+		data = entry.WithFields(log.Fields(keyval.ToMap(errors.GetDetails(err)))).Data
+	} else {
+		data = make(log.Fields, len(entry.Data)+2)
+		for k, v := range entry.Data {
+			data[k] = v
 		}
 	}
 
-	data := log.Fields{}
-	for k, v := range entry.Data {
-		data[k] = v
-	}
+	f.AddFingerprint(data, err)
+	f.AddStackID(data, entry)
+
 	return data
 }
 
+/*
+CopyEntry returns a shallow copy of the entry with the data
+	Formatters format the copy, so the entry of the caller (seen by hooks and other formatters) is not modified.
+*/
+func CopyEntry(entry *log.Entry, data log.Fields) *log.Entry {
+	formatted := *entry
+	formatted.Data = data
+
+	return &formatted
+}
+
+// AddFingerprint adds the fingerprint of the error to data, if enabled
+func (f *AdvancedFormatter) AddFingerprint(data log.Fields, err error) {
+	if (f.Flags&FlagErrorFingerprint) > 0 && err != nil {
+		data[KeyErrorFingerprint] = f.GetFingerprint(err)
+	}
+}
+
 // GetCallStack extracts simplified call stack from errors.StackTracer, if enabled
 func (f *AdvancedFormatter) GetCallStack(entry *log.Entry) []string {
+	return f.RenderCallStack(f.GetCallStackFrames(entry))
+}
+
+// RenderCallStack renders the frames by CallStackRenderer (compact lines, if not set)
+func (f *AdvancedFormatter) RenderCallStack(callStackFrames []CallStackFrame) []string {
+	if f.CallStackRenderer == nil {
+		return buildCallStackLines(callStackFrames, f.GetPackageTrimmer())
+	}
+
+	return f.CallStackRenderer.Render(callStackFrames, f.GetPackageTrimmer())
+}
+
+// GetPackageTrimmer returns PackageTrimmer, or DefaultPackageTrimmer, if not set
+func (f *AdvancedFormatter) GetPackageTrimmer() *PackageTrimmer {
+	if f.PackageTrimmer == nil {
+		return DefaultPackageTrimmer()
+	}
+
+	return f.PackageTrimmer
+}
+
+// TrimCaller trims the caller by GetPackageTrimmer, fits to TextFormatter.CallerPrettyfier
+func (f *AdvancedFormatter) TrimCaller(frame *runtime.Frame) (string, string) {
+	return f.GetPackageTrimmer().CallerPrettyfier(frame)
+}
+
+// GetCallStackWithSource extracts call stack lines with source context of application frames, if enabled
+// See FlagCallStackSourceContext and SourceContextSize
+func (f *AdvancedFormatter) GetCallStackWithSource(entry *log.Entry) []string {
+	if (f.Flags & FlagCallStackSourceContext) == 0 {
+		return f.GetCallStack(entry)
+	}
+
+	size := f.SourceContextSize
+	if size <= 0 {
+		size = SourceContextSize
+	}
+
+	renderer := f.CallStackRenderer
+	if renderer == nil {
+		renderer = CompactCallStackRenderer()
+	}
+
+	return buildCallStackSourceLines(f.GetCallStackFrames(entry), size, renderer, f.GetPackageTrimmer())
+}
+
+// GetCallStackLinks extracts call stack lines with source URLs (see SourceURLTemplate)
+func (f *AdvancedFormatter) GetCallStackLinks(entry *log.Entry) []CallStackLink {
+	return buildCallStackLinks(f.GetCallStackFrames(entry), f.SourceURLTemplate, f.GetPackageTrimmer())
+}
+
+// GetCallStackFrames extracts call stack frames from errors.StackTracer, if enabled
+// Returns empty, if the call stack was already printed (see StackRegistry and CheckStack)
+func (f *AdvancedFormatter) GetCallStackFrames(entry *log.Entry) []CallStackFrame {
+	callStackFrames := f.resolveCallStackFrames(entry)
+	if f.StackRegistry != nil && len(callStackFrames) > 0 {
+		check, ok := getStackCheck(entry)
+		if !ok {
+			check.stackID, check.printed = f.StackRegistry.Check(entry, callStackFrames)
+		}
+		if !check.printed {
+			return []CallStackFrame{}
+		}
+	}
+	if len(callStackFrames) > f.CallStackSkipLast+f.CallStackSkipFirst {
+		return f.FilterCallStackFrames(
+			callStackFrames[f.CallStackSkipFirst : len(callStackFrames)-f.CallStackSkipLast])
+	}
+
+	return []CallStackFrame{}
+}
+
+/*
+FilterCallStackFrames applies the frame filtering rules
+	CallStackDropPackages, FlagCallStackAppOnly (if there is any application frame)
+	and CallStackCollapsePackages ("... N frames elided" marker frame)
+*/
+func (f *AdvancedFormatter) FilterCallStackFrames(callStackFrames []CallStackFrame) []CallStackFrame {
+	trimmer := f.GetPackageTrimmer()
+	appOnly := false
+	if (f.Flags & FlagCallStackAppOnly) > 0 {
+		for _, frame := range callStackFrames {
+			if trimmer.IsApplicationFunction(frame.Function) {
+				appOnly = true
+				break
+			}
+		}
+	}
+
+	filteredFrames := make([]CallStackFrame, 0, len(callStackFrames))
+	for _, frame := range callStackFrames {
+		if IsFunctionInPackages(frame.Function, f.CallStackDropPackages) ||
+			(appOnly && !trimmer.IsApplicationFunction(frame.Function)) {
+			continue
+		}
+
+		if IsFunctionInPackages(frame.Function, f.CallStackCollapsePackages) {
+			if last := len(filteredFrames) - 1; last >= 0 && filteredFrames[last].Elided > 0 {
+				filteredFrames[last].Elided++
+			} else {
+				filteredFrames = append(filteredFrames, CallStackFrame{Elided: 1})
+			}
+			continue
+		}
+
+		filteredFrames = append(filteredFrames, frame)
+	}
+
+	return filteredFrames
+}
+
+/*
+CheckStack registers the call stack of the entry in StackRegistry, called once per Format
+	Returns a copy of the entry, carrying the call stack ID and the decision, whether
+	the call stack is printed (see AddStackID and GetCallStackFrames).
+	Returns the entry, if StackRegistry is not set, there is no call stack or it's already checked.
+*/
+func (f *AdvancedFormatter) CheckStack(entry *log.Entry) *log.Entry {
+	if f.StackRegistry == nil {
+		return entry
+	}
+	if _, checked := getStackCheck(entry); checked {
+		return entry
+	}
+	callStackFrames := f.resolveCallStackFrames(entry)
+	if len(callStackFrames) == 0 {
+		return entry
+	}
+
+	check := stackCheck{}
+	check.stackID, check.printed = f.StackRegistry.Check(entry, callStackFrames)
+
+	return withStackCheck(entry, check)
+}
+
+// IsStackSuppressed returns true, if the call stack was already printed, so it's left out (see CheckStack)
+func (f *AdvancedFormatter) IsStackSuppressed(entry *log.Entry) bool {
+	check, ok := getStackCheck(entry)
+
+	return f.StackRegistry != nil && ok && !check.printed
+}
+
+// AddStackID adds the call stack ID to data, if StackRegistry is set (see CheckStack)
+func (f *AdvancedFormatter) AddStackID(data log.Fields, entry *log.Entry) {
+	if f.StackRegistry != nil {
+		if check, ok := getStackCheck(entry); ok {
+			data[KeyStackID] = check.stackID
+		} else if callStackFrames := f.resolveCallStackFrames(entry); len(callStackFrames) > 0 {
+			data[KeyStackID] = StackID(callStackFrames)
+		}
+	}
+}
+
+// resolveCallStackFrames resolves all frames of the error call stack, if enabled
+func (f *AdvancedFormatter) resolveCallStackFrames(entry *log.Entry) []CallStackFrame {
 	if (f.Flags & (FlagCallStackInFields | FlagCallStackOnConsole | FlagCallStackInHTTPProblem)) > 0 {
 		if err := f.GetError(entry); err != nil {
 			var stackTracer StackTracer
 			if errors.As(err, &stackTracer) {
-				callStackLines := buildCallStackLines(stackTracer)
-				if len(callStackLines) > f.CallStackSkipLast {
-					return callStackLines[:len(callStackLines)-f.CallStackSkipLast]
-				}
+				return buildCallStackFrames(stackTracer)
 			}
 		}
 	}
 
-	return []string{}
+	return nil
 }
 
 /*RenderFieldValues renders Details with field values (%+v), if enabled
@@ -96,6 +288,10 @@ Forces rendering error by Error()
 */
 func (f *AdvancedFormatter) RenderFieldValues(data log.Fields) {
 	for key, value := range data {
+		switch value.(type) { // fast path of the frequent types, without reflection
+		case nil, string, int, int64, uint64, float64:
+			continue
+		}
 		if val := reflect.ValueOf(value); val.IsValid() {
 			err, isError := value.(error) // %+v prints out stack trace, too
 			if isError && err != nil {
@@ -0,0 +1,85 @@
+package errfmt
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/juju/rfc/rfc5424"
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const benchFlags = FlagExtractDetails | FlagCallStackInFields
+
+// benchFormatter is a formatter under benchmark, with the allocation budget of a Format call
+// (measured allocations + ~30% for Go version and race detector differences)
+type benchFormatter struct {
+	name      string
+	formatter log.Formatter
+	allocs    float64
+}
+
+func newBenchFormatters() []benchFormatter {
+	nestedJSON := NewAdvancedJSONFormatter(benchFlags|FlagNestedJSON, 2)
+	nestedJSON.DetailsKey = "error.details"
+
+	return []benchFormatter{
+		{"Text", NewAdvancedTextFormatter(benchFlags, 2), 125},
+		{"JSON", NewAdvancedJSONFormatter(benchFlags, 2), 135},
+		{"NestedJSON", nestedJSON, 110},
+		{"ECS", NewECSLogger(log.InfoLevel, benchFlags, 2).Formatter, 150},
+		{"OTel", NewOTelLogger(log.InfoLevel, benchFlags, 2).Formatter, 110},
+		{"GCP", NewGCPLogger(log.InfoLevel, benchFlags, 2).Formatter, 170},
+		{"Logfmt", NewAdvancedLogfmtFormatter(benchFlags, 2), 150},
+		{"Console", NewAdvancedConsoleFormatter(benchFlags, 2), 90},
+		{"Syslog", NewAdvancedSyslogFormatter(benchFlags, 2,
+			rfc5424.FacilityDaemon, rfc5424.Hostname{FQDN: "fqdn.host.com"}, "application", "PID", ""), 180},
+		{"CEF", NewAdvancedCEFFormatter(benchFlags, 2, "Vendor", "Product", "1.0"), 140},
+		{"GELF", NewAdvancedGELFFormatter(benchFlags, 2, "localhost"), 215},
+	}
+}
+
+// newBenchEntry makes an error entry with details, call stack and caller
+func newBenchEntry() *log.Entry {
+	logger := log.New()
+	logger.ReportCaller = true
+	entry := logger.WithError(GenerateDeepErrors()).WithFields(log.Fields{
+		"STR": "str",
+		"INT": 42,
+	}).WithTime(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	entry.Level = log.ErrorLevel
+	entry.Message = "USER MSG"
+	pc, file, line, _ := runtime.Caller(0)
+	entry.Caller = &runtime.Frame{PC: pc, File: file, Line: line, Function: runtime.FuncForPC(pc).Name()}
+
+	return entry
+}
+
+func BenchmarkFormat(b *testing.B) {
+	entry := newBenchEntry()
+	for _, bench := range newBenchFormatters() {
+		formatter := bench.formatter
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := formatter.Format(entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFormat_AllocationBudget(t *testing.T) {
+	entry := newBenchEntry()
+	for _, bench := range newBenchFormatters() {
+		formatter := bench.formatter
+		allocs := testing.AllocsPerRun(100, func() {
+			formatter.Format(entry) // nolint:errcheck,gosec
+		})
+		t.Logf("%s: %.0f allocs/op", bench.name, allocs)
+		assert.LessOrEqual(t, allocs, bench.allocs, bench.name)
+	}
+}
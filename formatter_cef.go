@@ -0,0 +1,247 @@
+package errfmt
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SIEMDialectCEF is the ArcSight Common Event Format (CEF:0)
+	SIEMDialectCEF = 0
+	// SIEMDialectLEEF is the IBM QRadar Log Event Extended Format (LEEF:1.0)
+	SIEMDialectLEEF = 1
+
+	// CEFKeyMessage is the extension key of the error message
+	CEFKeyMessage = "msg"
+	// CEFKeyReceiptTime is the CEF extension key of the event time (milliseconds since epoch)
+	CEFKeyReceiptTime = "rt"
+	// LEEFKeySeverity is the LEEF attribute key of the severity
+	LEEFKeySeverity = "sev"
+	// LEEFKeyDevTime is the LEEF attribute key of the event time
+	LEEFKeyDevTime = "devTime"
+	// LEEFKeyDevTimeFormat is the LEEF attribute key of the event time format
+	LEEFKeyDevTimeFormat = "devTimeFormat"
+
+	// leefDevTimeFormat is the devTime format (Go layout) and leefDevTimeFormatJava is the same in Java notation
+	leefDevTimeFormat     = "2006-01-02T15:04:05.000Z07:00"
+	leefDevTimeFormatJava = "yyyy-MM-dd'T'HH:mm:ss.SSSXXX"
+)
+
+/*
+NewCEFLogger builds a Logrus logger + CEF (ArcSight) formatter
+	See AdvancedCEFFormatter
+*/
+func NewCEFLogger(level log.Level, flags int, callStackSkipLast int,
+	vendor string, product string, version string,
+) *log.Logger {
+	logger := log.New()
+
+	logger.Formatter = NewAdvancedCEFFormatter(flags, callStackSkipLast, vendor, product, version)
+	logger.Level = level
+	logger.ReportCaller = true
+
+	return logger
+}
+
+/*
+NewLEEFLogger builds a Logrus logger + LEEF (QRadar) formatter
+	See AdvancedCEFFormatter
+*/
+func NewLEEFLogger(level log.Level, flags int, callStackSkipLast int,
+	vendor string, product string, version string,
+) *log.Logger {
+	logger := NewCEFLogger(level, flags, callStackSkipLast, vendor, product, version)
+	if f, ok := logger.Formatter.(*AdvancedCEFFormatter); ok {
+		f.Dialect = SIEMDialectLEEF
+	}
+
+	return logger
+}
+
+/*
+AdvancedCEFFormatter is a CEF / LEEF formatter for security event logging (SIEM)
+	Features:
+	* Header: Vendor, Product, Version, signature (event) ID, name (CEF only), severity (CEF only)
+	* Signature ID: MsgIDRules (for example error code), SignatureID or MsgIDDetails
+	* Name: entry.Message (or the error, if empty)
+	* Severity by LevelToSeverity (0-10, LEEF: 1-10 in sev attribute)
+	* Extensions (attributes) from fields and error details (see FlagExtractDetails),
+	  the error is rendered to msg, the time to rt (CEF) or devTime (LEEF)
+	* Escaping of '|', '=', '\' and new lines by CEF / LEEF spec
+*/
+type AdvancedCEFFormatter struct {
+	AdvancedFormatter
+	MsgIDRules
+	// Dialect is SIEMDialectCEF or SIEMDialectLEEF
+	Dialect int
+	Vendor  string
+	Product string
+	Version string
+	// SignatureID is the fixed signature ID, if MsgIDRules is not matched
+	SignatureID     string
+	LevelToSeverity map[log.Level]int
+	SortingFunc     func([]string)
+}
+
+// NewAdvancedCEFFormatter makes a new AdvancedCEFFormatter (CEF dialect)
+func NewAdvancedCEFFormatter(flags int, callStackSkipLast int,
+	vendor string, product string, version string,
+) *AdvancedCEFFormatter {
+	return &AdvancedCEFFormatter{
+		AdvancedFormatter: AdvancedFormatter{
+			Flags:             flags,
+			CallStackSkipLast: callStackSkipLast,
+		},
+		Dialect:         SIEMDialectCEF,
+		Vendor:          vendor,
+		Product:         product,
+		Version:         version,
+		LevelToSeverity: DefaultLevelToCEFSeverity(),
+		SortingFunc:     SortingFuncDecorator(AdvancedFieldOrder()),
+	}
+}
+
+// DefaultLevelToCEFSeverity maps logrus levels to CEF severity (0-10)
+func DefaultLevelToCEFSeverity() map[log.Level]int {
+	return map[log.Level]int{
+		log.PanicLevel: 10,
+		log.FatalLevel: 9,
+		log.ErrorLevel: 7,
+		log.WarnLevel:  5,
+		log.InfoLevel:  3,
+		log.DebugLevel: 1,
+		log.TraceLevel: 0,
+	}
+}
+
+// GetClashingFields returns the field names, which are renamed in extensions
+func (f *AdvancedCEFFormatter) GetClashingFields() []string {
+	return append(f.AdvancedFormatter.GetClashingFields(),
+		CEFKeyMessage, CEFKeyReceiptTime, LEEFKeySeverity, LEEFKeyDevTime, LEEFKeyDevTimeFormat)
+}
+
+// GetSignatureID computes the signature (event) ID: MsgIDRules, SignatureID or MsgIDDetails
+func (f *AdvancedCEFFormatter) GetSignatureID(entry *log.Entry) string {
+	if signatureID := f.ResolveMsgID(entry, f.GetError(entry)); signatureID != "" {
+		return signatureID
+	}
+	if f.SignatureID != "" {
+		return f.SignatureID
+	}
+
+	return MsgIDDetails
+}
+
+// Format implements logrus.Formatter interface
+func (f *AdvancedCEFFormatter) Format(entry *log.Entry) ([]byte, error) { //nolint:funlen
+	entry = f.CheckStack(entry)
+	data := f.PrepareFields(entry, f.GetClashingFields())
+	callStackLines := f.GetCallStack(entry)
+	delete(data, log.FieldKeyLevel)
+
+	name := entry.Message
+	if err := f.GetError(entry); err != nil {
+		data[CEFKeyMessage] = err.Error()
+		if name == "" {
+			name = err.Error()
+		}
+	}
+	delete(data, log.ErrorKey)
+
+	severity := f.LevelToSeverity[entry.Level]
+	textPart := &strings.Builder{}
+	if f.Dialect == SIEMDialectLEEF {
+		if severity < 1 {
+			severity = 1
+		}
+		data[LEEFKeySeverity] = severity
+		data[LEEFKeyDevTime] = entry.Time.Format(leefDevTimeFormat)
+		data[LEEFKeyDevTimeFormat] = leefDevTimeFormatJava
+
+		fmt.Fprintf(textPart, "LEEF:1.0|%s|%s|%s|%s|",
+			CEFHeaderValue(f.Vendor), CEFHeaderValue(f.Product), CEFHeaderValue(f.Version),
+			CEFHeaderValue(f.GetSignatureID(entry)))
+	} else {
+		data[CEFKeyReceiptTime] = entry.Time.UnixNano() / 1e6
+
+		fmt.Fprintf(textPart, "CEF:0|%s|%s|%s|%s|%s|%d|",
+			CEFHeaderValue(f.Vendor), CEFHeaderValue(f.Product), CEFHeaderValue(f.Version),
+			CEFHeaderValue(f.GetSignatureID(entry)), CEFHeaderValue(name), severity)
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	f.SortingFunc(keys)
+
+	separator := " "
+	if f.Dialect == SIEMDialectLEEF {
+		separator = "\t"
+	}
+	for i, key := range keys {
+		if i > 0 {
+			textPart.WriteString(separator)
+		}
+		textPart.WriteString(FixCEFExtensionKey(key))
+		textPart.WriteByte('=')
+		textPart.WriteString(CEFExtensionValue(cefValueString(data[key])))
+	}
+	textPart.WriteByte('\n')
+
+	if (f.Flags & FlagCallStackOnConsole) > 0 {
+		return f.AppendCallStack([]byte(textPart.String()), callStackLines), nil
+	}
+
+	return []byte(textPart.String()), nil
+}
+
+// cefHeaderReplacer and cefExtensionReplacer are built once (strings.Replacer is safe for concurrent use)
+var (
+	cefHeaderReplacer    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")  // nolint:gochecknoglobals,lll
+	cefExtensionReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`, "\t", `\t`) // nolint:gochecknoglobals,lll
+)
+
+// CEFHeaderValue escapes '\' and '|', replaces new lines to space (CEF / LEEF header)
+func CEFHeaderValue(value string) string {
+	return cefHeaderReplacer.Replace(value)
+}
+
+// CEFExtensionValue escapes '\', '=', new lines and tabs (CEF extension / LEEF attribute value)
+func CEFExtensionValue(value string) string {
+	return cefExtensionReplacer.Replace(value)
+}
+
+// FixCEFExtensionKey replaces invalid extension key characters to '_'
+func FixCEFExtensionKey(key string) string {
+	if strings.IndexFunc(key, isInvalidCEFExtensionKeyRune) < 0 {
+		return key
+	}
+
+	str := strings.Builder{}
+	for _, b := range []byte(key) {
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' || b == '.' {
+			str.WriteByte(b) //nolint:gosec
+		} else {
+			str.WriteByte('_') //nolint:gosec
+		}
+	}
+
+	return str.String()
+}
+
+// isInvalidCEFExtensionKeyRune returns true, if the character is not allowed in extension keys
+func isInvalidCEFExtensionKeyRune(r rune) bool {
+	return !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.')
+}
+
+// cefValueString renders the call stack lines by new lines, others by logfmtValueString
+func cefValueString(value interface{}) string {
+	if lines, ok := value.([]string); ok {
+		return strings.Join(lines, "\n")
+	}
+
+	return logfmtValueString(value)
+}
@@ -0,0 +1,81 @@
+package errfmt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newCEFLoggerMock(flags int, callStackSkipLast int) *LoggerMock {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewCEFLogger(log.InfoLevel, flags, callStackSkipLast, "Ven|dor", "Product", "1.0")
+	buf := new(bytes.Buffer)
+	loggerMock := &LoggerMock{
+		Logger:   logger,
+		outBuf:   buf,
+		exitCode: -1,
+	}
+	loggerMock.Out = buf
+	loggerMock.ExitFunc = loggerMock.exit
+
+	return loggerMock
+}
+
+func TestCEF_WithError_CallStackInFields(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newCEFLoggerMock(FlagExtractDetails|FlagCallStackInFields, 2)
+	ts := time.Unix(1571170345, 123456789)
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).WithField("msg", "a=b").Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	// nolint:lll
+	assert.Equal(t, `CEF:0|Ven\|dor|Product|1.0|DETAILS_MSG|USER MSG|7|func=`+funcName+` msg=MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing "NO_NUMBER": invalid syntax file=formatter_cef_test.go:0 K0_1=V0_1 K0_2=V0_2 K1_1=V1_1 K1_2=V1_2 K3_2=V3 space K3_5=V3"doublequote K3_6=V3%percent K3_3=V3:column K3_3=V3;semicolumn K3_1=V3\=equal K5_bool=true K5_int=12 K5_map={"1":"ONE","2":"TWO"} K5_struct={"Text":"text","Integer":42,"Bool":true} fields.msg=a\=b rt=1571170345123 callstack=errfmt.newWithDetails() errfmt.go:0\nerrfmt.GenerateDeepErrors() errfmt.go:0\n`+funcName+`() formatter_cef_test.go:0
+`, replaceCallLine(loggerMock.outBuf.String()))
+}
+
+func TestLEEF_SignatureID(t *testing.T) {
+	logger := NewLEEFLogger(log.InfoLevel, FlagExtractDetails, 0, "Vendor", "Product", "1.0")
+	buf := new(bytes.Buffer)
+	logger.Out = buf
+	logger.ReportCaller = false
+	formatter, ok := logger.Formatter.(*AdvancedCEFFormatter)
+	assert.True(t, ok, "formatter")
+	formatter.MsgIDDetailKey = "code"
+	ts := time.Unix(1571170345, 123456789).UTC()
+
+	err := errors.WithDetails(errors.New("denied"), "code", "AUTH|401")
+	logger.WithError(err).WithTime(ts).WithField("user", "a\tb").Warn("LOGIN")
+
+	assert.Equal(t, "LEEF:1.0|Vendor|Product|1.0|AUTH\\|401|msg=denied\tcode=AUTH|401\tdevTime=2019-10-15T20:12:25.123Z\t"+
+		"devTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSXXX\tsev=5\tuser=a\\tb\n", buf.String())
+}
+
+func TestCEF_GetSignatureID(t *testing.T) {
+	errSentinel := errors.NewPlain("sentinel")
+	formatter := NewAdvancedCEFFormatter(FlagNone, 0, "Vendor", "Product", "1.0")
+	entry := log.NewEntry(log.New())
+	assert.Equal(t, MsgIDDetails, formatter.GetSignatureID(entry))
+
+	formatter.SignatureID = "FIXED"
+	assert.Equal(t, "FIXED", formatter.GetSignatureID(entry))
+
+	formatter.RegisterMsgIDError(errSentinel, "SENTINEL")
+	assert.Equal(t, "SENTINEL", formatter.GetSignatureID(entry.WithError(errors.Wrap(errSentinel, "wrapped"))))
+}
+
+func TestCEFExtensionValue(t *testing.T) {
+	assert.Equal(t, `a\\b\=c|d\ne`, CEFExtensionValue("a\\b=c|d\ne"))
+	assert.Equal(t, `a\\b=c\|d e`, CEFHeaderValue("a\\b=c|d\ne"))
+	assert.Equal(t, "K3_2.x", FixCEFExtensionKey("K3 2.x"))
+}
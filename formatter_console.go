@@ -0,0 +1,266 @@
+package errfmt
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// EnvNoColor disables colors, if set to non-empty, see https://no-color.org/
+	EnvNoColor = "NO_COLOR"
+	// ConsoleTimestampFormat is the default timestamp format of AdvancedConsoleFormatter
+	ConsoleTimestampFormat = "15:04:05.000"
+	// ConsoleMessageWidth is the default message column width of AdvancedConsoleFormatter
+	ConsoleMessageWidth = 40
+	// ConsoleBufferSize is the initial buffer size of AdvancedConsoleFormatter
+	ConsoleBufferSize = 1024
+
+	colorRed    = 31
+	colorYellow = 33
+	colorBlue   = 36
+	colorGray   = 37
+	colorBold   = 1
+	colorDim    = 2
+	colorReset  = "\x1b[0m"
+)
+
+/*
+NewConsoleLogger builds a Logrus logger + human-friendly console formatter (for development)
+	Features:
+	* CallStackSkipLast
+	* CallStackOnConsole or CallStackInFields: call stack block
+	* Colors (with TTY detection and NO_COLOR support)
+*/
+func NewConsoleLogger(level log.Level, flags int, callStackSkipLast int,
+) *log.Logger {
+	logger := log.New()
+
+	logger.Formatter = NewAdvancedConsoleFormatter(flags, callStackSkipLast)
+	logger.Level = level
+	logger.ReportCaller = true
+
+	return logger
+}
+
+/*
+AdvancedConsoleFormatter is a human-friendly console formatter (for development)
+	Features:
+	* Colored level
+	* Aligned columns: level, time, message, caller
+	* Fields and error details in an indented key-value block
+	* Call stack with short file names and highlighted application frames (see AddSkipPackageFromStackTrace)
+*/
+type AdvancedConsoleFormatter struct {
+	AdvancedFormatter
+	// TimestampFormat is ConsoleTimestampFormat, if empty
+	TimestampFormat string
+	// MessageWidth is the min. width of the message column
+	MessageWidth int
+	// ForceColors enables colors, even if the output is not a TTY
+	ForceColors bool
+	// DisableColors disables colors
+	DisableColors bool
+	SortingFunc   func([]string)
+
+	terminalInitOnce sync.Once
+	isTerminal       bool
+}
+
+// NewAdvancedConsoleFormatter makes a new AdvancedConsoleFormatter
+func NewAdvancedConsoleFormatter(flags int, callStackSkipLast int) *AdvancedConsoleFormatter {
+	return &AdvancedConsoleFormatter{
+		AdvancedFormatter: AdvancedFormatter{
+			Flags:             flags,
+			CallStackSkipLast: callStackSkipLast,
+		},
+		TimestampFormat: ConsoleTimestampFormat,
+		MessageWidth:    ConsoleMessageWidth,
+		SortingFunc:     SortingFuncDecorator(AdvancedFieldOrder()),
+	}
+}
+
+// Format implements logrus.Formatter interface
+func (f *AdvancedConsoleFormatter) Format(entry *log.Entry) ([]byte, error) {
+	entry = f.CheckStack(entry)
+	colored := f.isColored(entry)
+	data := f.PrepareFields(entry, f.GetClashingFields())
+
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = ConsoleTimestampFormat
+	}
+
+	text := &bytes.Buffer{}
+	text.Grow(ConsoleBufferSize)
+	levelText := strings.ToUpper(entry.Level.String())
+	if len(levelText) > 4 {
+		levelText = levelText[:4]
+	}
+	writeColored(text, colored, levelColor(entry.Level), levelText, 4)
+	text.WriteByte(' ')
+	if colored {
+		writeColorStart(text, colorDim)
+	}
+	var timestamp [64]byte
+	text.Write(entry.Time.AppendFormat(timestamp[:0], timestampFormat))
+	if colored {
+		text.WriteString(colorReset)
+	}
+	text.WriteByte(' ')
+	writePadded(text, entry.Message, f.MessageWidth)
+	if entry.HasCaller() {
+		funcVal, fileVal := f.TrimCaller(entry.Caller)
+		text.WriteByte(' ')
+		if colored {
+			writeColorStart(text, colorDim)
+		}
+		text.WriteString(funcVal)
+		text.WriteByte(' ')
+		text.WriteString(fileVal)
+		if colored {
+			text.WriteString(colorReset)
+		}
+	}
+	text.WriteByte('\n')
+
+	keys := make([]string, 0, len(data))
+	keyWidth := 0
+	for key := range data {
+		switch key {
+		case log.FieldKeyLevel, log.FieldKeyFunc, log.FieldKeyFile, KeyCallStack:
+			continue
+		}
+		keys = append(keys, key)
+		if len(key) > keyWidth {
+			keyWidth = len(key)
+		}
+	}
+	f.SortingFunc(keys)
+	valueIndent := "\n    " + strings.Repeat(" ", keyWidth+1)
+	for _, key := range keys {
+		text.WriteString("    ")
+		writeColored(text, colored, levelColor(entry.Level), key, keyWidth)
+		text.WriteByte(' ')
+		text.WriteString(strings.Replace(logfmtValueString(data[key]), "\n", valueIndent, -1))
+		text.WriteByte('\n')
+	}
+
+	if (f.Flags & (FlagCallStackOnConsole | FlagCallStackInFields)) > 0 {
+		f.writeCallStack(text, colored, f.GetCallStackFrames(entry))
+	}
+
+	return text.Bytes(), nil
+}
+
+/*
+writeCallStack writes the call stack lines by CallStackRenderer (like RenderCallStack)
+	The lines of application frames are highlighted, other lines are dimmed.
+*/
+func (f *AdvancedConsoleFormatter) writeCallStack(text *bytes.Buffer, colored bool, callStackFrames []CallStackFrame) {
+	if len(callStackFrames) == 0 {
+		return
+	}
+
+	renderer := f.CallStackRenderer
+	if renderer == nil {
+		renderer = CompactCallStackRenderer()
+	}
+	trimmer := f.GetPackageTrimmer()
+	for _, line := range renderer.Header {
+		writeCallStackLine(text, colored, false, line)
+	}
+	for _, frame := range callStackFrames {
+		application := trimmer.IsApplicationFunction(frame.Function)
+		for _, line := range renderer.RenderFrame(frame, trimmer) {
+			writeCallStackLine(text, colored, application, line)
+		}
+	}
+}
+
+// writeCallStackLine writes a call stack line, the line of an application frame is highlighted
+func writeCallStackLine(text *bytes.Buffer, colored bool, application bool, line string) {
+	if application {
+		text.WriteString("  > ")
+		writeColored(text, colored, colorBold, line, 0)
+	} else {
+		text.WriteString("    ")
+		writeColored(text, colored, colorDim, line, 0)
+	}
+	text.WriteByte('\n')
+}
+
+// isColored returns true, if colors are enabled
+func (f *AdvancedConsoleFormatter) isColored(entry *log.Entry) bool {
+	if f.DisableColors || os.Getenv(EnvNoColor) != "" {
+		return false
+	}
+	if f.ForceColors {
+		return true
+	}
+
+	f.terminalInitOnce.Do(func() {
+		if entry.Logger != nil {
+			f.isTerminal = IsTerminal(entry.Logger.Out)
+		}
+	})
+
+	return f.isTerminal
+}
+
+// IsTerminal returns true, if the writer is a character device (TTY)
+func IsTerminal(w io.Writer) bool {
+	if file, ok := w.(*os.File); ok {
+		if stat, err := file.Stat(); err == nil {
+			return (stat.Mode() & os.ModeCharDevice) != 0
+		}
+	}
+
+	return false
+}
+
+// levelColor returns the ANSI color of the level
+func levelColor(level log.Level) int {
+	switch level {
+	case log.DebugLevel, log.TraceLevel:
+		return colorGray
+	case log.WarnLevel:
+		return colorYellow
+	case log.ErrorLevel, log.FatalLevel, log.PanicLevel:
+		return colorRed
+	default:
+		return colorBlue
+	}
+}
+
+// writeColored writes the text padded to width, wrapped into ANSI color escape sequence, if enabled
+func writeColored(text *bytes.Buffer, colored bool, color int, value string, width int) {
+	if colored {
+		writeColorStart(text, color)
+	}
+	writePadded(text, value, width)
+	if colored {
+		text.WriteString(colorReset)
+	}
+}
+
+// writeColorStart writes the ANSI color escape sequence
+func writeColorStart(text *bytes.Buffer, color int) {
+	text.WriteString("\x1b[")
+	text.WriteString(strconv.Itoa(color))
+	text.WriteByte('m')
+}
+
+// writePadded writes the text, padded by spaces to width (same as "%-*s")
+func writePadded(text *bytes.Buffer, value string, width int) {
+	text.WriteString(value)
+	for n := utf8.RuneCountInString(value); n < width; n++ {
+		text.WriteByte(' ')
+	}
+}
@@ -0,0 +1,122 @@
+package errfmt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newConsoleLoggerMock(flags int, callStackSkipLast int) *LoggerMock {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewConsoleLogger(log.InfoLevel, flags, callStackSkipLast)
+	buf := new(bytes.Buffer)
+	loggerMock := &LoggerMock{
+		Logger:   logger,
+		outBuf:   buf,
+		exitCode: -1,
+	}
+	loggerMock.Out = buf
+	loggerMock.ExitFunc = loggerMock.exit
+
+	return loggerMock
+}
+
+func TestConsole_WithError_CallStackOnConsole(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newConsoleLoggerMock(
+		FlagExtractDetails|FlagCallStackOnConsole,
+		2)
+	ts := time.Now()
+	tsText := ts.Format(ConsoleTimestampFormat)
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	// nolint:lll
+	assert.Equal(t, `ERRO `+tsText+` USER MSG                                 `+funcName+` formatter_console_test.go:0
+    error     MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing "NO_NUMBER": invalid syntax
+    K0_1      V0_1
+    K0_2      V0_2
+    K1_1      V1_1
+    K1_2      V1_2
+    K3 2      V3 space
+    K3"5      V3"doublequote
+    K3%6      V3%percent
+    K3:3      V3:column
+    K3;3      V3;semicolumn
+    K3=1      V3=equal
+    K5_bool   true
+    K5_int    12
+    K5_map    {"1":"ONE","2":"TWO"}
+    K5_struct {"Text":"text","Integer":42,"Bool":true}
+  > errfmt.newWithDetails() errfmt.go:0
+  > errfmt.GenerateDeepErrors() errfmt.go:0
+  > `+funcName+`() formatter_console_test.go:0
+`, replaceCallLine(loggerMock.outBuf.String()))
+}
+
+func TestConsole_CallStackRenderer(t *testing.T) {
+	funcName := FunctionName()
+	loggerMock := newConsoleLoggerMock(FlagCallStackOnConsole, 2)
+	GetAdvancedFormatter(loggerMock.Formatter).CallStackRenderer = JavaCallStackRenderer()
+
+	loggerMock.WithError(GenerateDeepErrors()).Error("USER MSG")
+	output := replaceCallLine(loggerMock.outBuf.String())
+	assert.Contains(t, output, `
+  > at github.com/pgillich/errfmt.newWithDetails(errfmt.go:0)
+  > at github.com/pgillich/errfmt.GenerateDeepErrors(errfmt.go:0)
+  > at `+funcName+`(formatter_console_test.go:0)
+`)
+
+	GetAdvancedFormatter(loggerMock.Formatter).CallStackRenderer = GoPanicCallStackRenderer()
+	loggerMock.outBuf.Reset()
+	loggerMock.WithError(GenerateDeepErrors()).Error("USER MSG")
+	assert.Contains(t, loggerMock.outBuf.String(), "\n    goroutine 1 [running]:\n  > github.com/pgillich/errfmt.newWithDetails(...)\n  > \t/")
+}
+
+func TestConsole_Colors(t *testing.T) {
+	loggerMock := newConsoleLoggerMock(FlagNone, 0)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedConsoleFormatter)
+	assert.True(t, ok, "AdvancedConsoleFormatter")
+
+	loggerMock.WithField("K", "V").Warn("USER MSG")
+	assert.NotContains(t, loggerMock.outBuf.String(), "\x1b[", "not a TTY")
+
+	formatter.ForceColors = true
+	loggerMock.outBuf.Reset()
+	loggerMock.WithField("K", "V").Warn("USER MSG")
+	assert.True(t, strings.HasPrefix(loggerMock.outBuf.String(), "\x1b[33mWARN\x1b[0m "), loggerMock.outBuf.String())
+	assert.Contains(t, loggerMock.outBuf.String(), "    \x1b[33mK\x1b[0m V\n")
+
+	noColor, hasNoColor := os.LookupEnv(EnvNoColor)
+	assert.Nil(t, os.Setenv(EnvNoColor, "1"))
+	defer func() {
+		if hasNoColor {
+			os.Setenv(EnvNoColor, noColor) // nolint:errcheck,gosec
+		} else {
+			os.Unsetenv(EnvNoColor) // nolint:errcheck,gosec
+		}
+	}()
+	loggerMock.outBuf.Reset()
+	loggerMock.WithField("K", "V").Warn("USER MSG")
+	assert.NotContains(t, loggerMock.outBuf.String(), "\x1b[", EnvNoColor)
+}
+
+func TestIsApplicationFunction(t *testing.T) {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	assert.True(t, IsApplicationFunction(FunctionName()))
+	assert.False(t, IsApplicationFunction("runtime.goexit"))
+	assert.False(t, IsApplicationFunction("testing.tRunner"))
+}
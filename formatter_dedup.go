@@ -0,0 +1,260 @@
+package errfmt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// KeyDedupSuppressed is the field name of the suppressed count in summaries
+	KeyDedupSuppressed = "suppressed"
+	// KeyDedupFirst is the field name of the first suppressed timestamp in summaries
+	KeyDedupFirst = "suppressed_first"
+	// KeyDedupLast is the field name of the last suppressed timestamp in summaries
+	KeyDedupLast = "suppressed_last"
+	// DedupWindow is the default suppression window
+	DedupWindow = time.Minute
+)
+
+/*
+DedupFormatter is a logrus.Formatter wrapper, suppressing repeated errors
+	Entries with the same error fingerprint (see Fingerprint) and message template
+	(see FingerprintTemplate) are printed Burst times in a Window, further ones are suppressed.
+	The summary of suppressed entries (suppressed count, first and last timestamps,
+	the first suppressed error as representative call stack) is printed before
+	the next printed occurrence or by Flush (see Start for periodic flush).
+	Idle groups (window is over) are also expired by Format, their summaries are printed
+	before the formatted entry. Entries without error are not suppressed.
+	The fingerprint is computed by the wrapped formatter (see AdvancedFormatter.GetFingerprint).
+*/
+type DedupFormatter struct {
+	log.Formatter
+	Window time.Duration
+	// Burst is the number of printed entries in a Window
+	Burst int
+
+	mu         sync.Mutex
+	states     map[string]*dedupState
+	lastExpire time.Time
+}
+
+// dedupSummaryKey is the context key, marking the summary entries (callers cannot set it)
+type dedupSummaryKey struct{}
+
+// isDedupSummary tells, if the entry is a summary, built by DedupFormatter
+func isDedupSummary(entry *log.Entry) bool {
+	return entry.Context != nil && entry.Context.Value(dedupSummaryKey{}) != nil
+}
+
+// dedupState is the suppression state of an error group
+type dedupState struct {
+	windowStart time.Time
+	printed     int
+	suppressed  int
+	first       time.Time
+	last        time.Time
+	// representative is the first suppressed entry
+	representative *log.Entry
+}
+
+// NewDedupFormatter makes a new DedupFormatter, wrapping the formatter (window is DedupWindow, if not positive)
+func NewDedupFormatter(formatter log.Formatter, window time.Duration) *DedupFormatter {
+	if window <= 0 {
+		window = DedupWindow
+	}
+
+	return &DedupFormatter{
+		Formatter: formatter,
+		Window:    window,
+		Burst:     1,
+		states:    map[string]*dedupState{},
+	}
+}
+
+// Format implements logrus.Formatter interface
+func (f *DedupFormatter) Format(entry *log.Entry) ([]byte, error) {
+	err := (&AdvancedFormatter{}).GetError(entry)
+	if err == nil || isDedupSummary(entry) {
+		return f.Formatter.Format(entry)
+	}
+
+	key := f.fingerprint(err) + "\n" + FingerprintTemplate(entry.Message)
+	now := entry.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	f.mu.Lock()
+	summaries := f.expireIdle(key, now)
+	state, ok := f.states[key]
+	if !ok {
+		state = &dedupState{windowStart: now}
+		f.states[key] = state
+	}
+
+	if now.Sub(state.windowStart) >= f.Window {
+		if summary := state.takeSummary(); summary != nil {
+			summaries = append(summaries, summary)
+		}
+		state.windowStart = now
+		state.printed = 0
+	}
+
+	suppressed := state.printed >= f.Burst
+	if suppressed {
+		state.suppress(entry, now)
+	} else {
+		state.printed++
+	}
+	f.mu.Unlock()
+
+	textPart := []byte{}
+	for _, summary := range summaries {
+		summaryPart, err := f.Formatter.Format(summary)
+		if err != nil {
+			return nil, err
+		}
+		textPart = append(textPart, summaryPart...)
+	}
+	if suppressed {
+		return textPart, nil
+	}
+
+	entryPart, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(textPart, entryPart...), nil
+}
+
+// fingerprint returns the error fingerprint by the wrapped formatter (Fingerprint, if it's not an advanced formatter)
+func (f *DedupFormatter) fingerprint(err error) string {
+	if advancedFormatter := GetAdvancedFormatter(f.Formatter); advancedFormatter != nil {
+		return advancedFormatter.GetFingerprint(err)
+	}
+
+	return Fingerprint(err)
+}
+
+// expireIdle removes the idle groups (except key, at most once per Window) and returns their summaries, must be called with locked mu
+func (f *DedupFormatter) expireIdle(key string, now time.Time) []*log.Entry {
+	summaries := []*log.Entry{}
+	if now.Sub(f.lastExpire) < f.Window {
+		return summaries
+	}
+	f.lastExpire = now
+
+	for stateKey, state := range f.states {
+		if stateKey != key && now.Sub(state.windowStart) >= f.Window {
+			if summary := state.takeSummary(); summary != nil {
+				summaries = append(summaries, summary)
+			}
+			delete(f.states, stateKey)
+		}
+	}
+
+	return summaries
+}
+
+/*
+Flush logs the summaries of the suppressed entries by the logger
+	Only the groups with expired window are flushed, if force is false.
+	Panic level summaries are logged on error level. The caller of flushed summaries
+	is Flush (see logrus.Logger.ReportCaller).
+*/
+func (f *DedupFormatter) Flush(logger *log.Logger, force bool) {
+	now := time.Now()
+	summaries := []*log.Entry{}
+
+	f.mu.Lock()
+	for key, state := range f.states {
+		if force || now.Sub(state.windowStart) >= f.Window {
+			if summary := state.takeSummary(); summary != nil {
+				summaries = append(summaries, summary)
+			}
+			delete(f.states, key)
+		}
+	}
+	f.mu.Unlock()
+
+	for _, summary := range summaries {
+		summary.Logger = logger
+		level := summary.Level
+		if level == log.PanicLevel {
+			level = log.ErrorLevel
+		}
+		summary.Log(level, summary.Message)
+	}
+}
+
+// Start flushes the summaries by the logger periodically (by Window), until stop is called
+func (f *DedupFormatter) Start(logger *log.Logger) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(f.Window)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.Flush(logger, false)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// suppress counts the suppressed entry, the first one is kept as representative
+func (s *dedupState) suppress(entry *log.Entry, now time.Time) {
+	if s.suppressed == 0 {
+		s.first = now
+		s.representative = entry.WithFields(log.Fields{})
+		s.representative.Level = entry.Level
+		s.representative.Message = entry.Message
+		s.representative.Caller = entry.Caller
+	}
+	s.suppressed++
+	s.last = now
+}
+
+// takeSummary builds the summary entry of the suppressed entries (nil, if none) and resets the counter
+func (s *dedupState) takeSummary() *log.Entry {
+	if s.suppressed == 0 {
+		return nil
+	}
+
+	summary := s.representative.WithFields(log.Fields{
+		KeyDedupSuppressed: s.suppressed,
+		KeyDedupFirst:      s.first.Format(time.RFC3339Nano),
+		KeyDedupLast:       s.last.Format(time.RFC3339Nano),
+	}).WithTime(s.last)
+	ctx := summary.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	summary = summary.WithContext(context.WithValue(ctx, dedupSummaryKey{}, true))
+	summary.Level = s.representative.Level
+	summary.Message = s.representative.Message
+	summary.Caller = s.representative.Caller
+
+	s.suppressed = 0
+	s.representative = nil
+
+	return summary
+}
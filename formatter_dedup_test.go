@@ -0,0 +1,154 @@
+package errfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newDedupLogger(window time.Duration) (*log.Logger, *DedupFormatter, *bytes.Buffer) {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewJSONLogger(log.InfoLevel, FlagCallStackInFields, 0)
+	formatter := NewDedupFormatter(logger.Formatter, window)
+	logger.Formatter = formatter
+	buf := new(bytes.Buffer)
+	logger.Out = buf
+
+	return logger, formatter, buf
+}
+
+func parseJSONLines(t *testing.T, text string) []map[string]interface{} {
+	messages := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		message := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &message), line)
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+func newDedupError(id int) error {
+	return errors.WrapIf(errors.NewWithDetails("connection refused", "id", id), "cannot connect")
+}
+
+func TestDedupFormatter_Window(t *testing.T) {
+	logger, _, buf := newDedupLogger(time.Minute)
+	ts := time.Unix(1571170345, 0)
+
+	for i := 0; i < 5; i++ {
+		logger.WithError(newDedupError(i)).WithTime(ts.Add(time.Duration(i) * time.Second)).Error("DB DOWN")
+	}
+	logger.WithError(errors.New("other")).WithTime(ts).Error("DB DOWN")
+	logger.WithTime(ts).Info("NO ERROR")
+	logger.WithTime(ts).Info("NO ERROR")
+
+	messages := parseJSONLines(t, buf.String())
+	assert.Len(t, messages, 4)
+	assert.Equal(t, "cannot connect: connection refused", messages[0][log.ErrorKey])
+	assert.Equal(t, "other", messages[1][log.ErrorKey])
+	assert.NotContains(t, messages[0], KeyDedupSuppressed)
+
+	buf.Reset()
+	logger.WithError(newDedupError(9)).WithTime(ts.Add(time.Minute)).Error("DB DOWN")
+	messages = parseJSONLines(t, buf.String())
+	assert.Len(t, messages, 2)
+	assert.Equal(t, float64(4), messages[0][KeyDedupSuppressed])
+	assert.Equal(t, ts.Add(time.Second).Format(time.RFC3339Nano), messages[0][KeyDedupFirst])
+	assert.Equal(t, ts.Add(4*time.Second).Format(time.RFC3339Nano), messages[0][KeyDedupLast])
+	assert.Equal(t, "DB DOWN", messages[0][log.FieldKeyMsg])
+	assert.NotEmpty(t, messages[0][KeyCallStack])
+	assert.NotContains(t, messages[1], KeyDedupSuppressed)
+}
+
+func TestDedupFormatter_Flush(t *testing.T) {
+	logger, formatter, buf := newDedupLogger(20 * time.Millisecond)
+	formatter.Burst = 2
+
+	for i := 0; i < 5; i++ {
+		logger.WithError(newDedupError(i)).Error("DB DOWN")
+	}
+	assert.Len(t, parseJSONLines(t, buf.String()), 2)
+
+	buf.Reset()
+	formatter.Flush(logger, false)
+	assert.Empty(t, buf.String(), "window is not expired")
+
+	stop := formatter.Start(logger)
+	assert.Eventually(t, func() bool {
+		formatter.mu.Lock()
+		defer formatter.mu.Unlock()
+		return len(formatter.states) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	stop()
+	stop()
+
+	messages := parseJSONLines(t, buf.String())
+	assert.Len(t, messages, 1)
+	assert.Equal(t, float64(3), messages[0][KeyDedupSuppressed])
+	assert.Equal(t, "error", messages[0][log.FieldKeyLevel])
+
+	buf.Reset()
+	formatter.Flush(logger, true)
+	assert.Empty(t, buf.String(), "already flushed")
+}
+
+func TestDedupFormatter_ExpireIdle(t *testing.T) {
+	logger, formatter, buf := newDedupLogger(time.Minute)
+	ts := time.Unix(1571170345, 0)
+
+	logger.WithError(newDedupError(1)).WithTime(ts).Error("DB DOWN")
+	logger.WithError(newDedupError(2)).WithTime(ts.Add(time.Second)).Error("DB DOWN")
+	logger.WithError(errors.New("other")).WithTime(ts).Error("OTHER")
+	assert.Len(t, formatter.states, 2)
+
+	buf.Reset()
+	logger.WithError(errors.New("third")).WithTime(ts.Add(2 * time.Minute)).Error("THIRD")
+	assert.Len(t, formatter.states, 1, "idle groups are expired")
+
+	messages := parseJSONLines(t, buf.String())
+	assert.Len(t, messages, 2)
+	assert.Equal(t, float64(1), messages[0][KeyDedupSuppressed])
+	assert.Equal(t, "DB DOWN", messages[0][log.FieldKeyMsg])
+	assert.Equal(t, "THIRD", messages[1][log.FieldKeyMsg])
+}
+
+func TestDedupFormatter_FormatterFingerprint(t *testing.T) {
+	logger, formatter, buf := newDedupLogger(time.Minute)
+	errs := []error{}
+	for i := 0; i < 2; i++ {
+		if i == 0 {
+			errs = append(errs, errors.New("connection refused"))
+		} else {
+			errs = append(errs, errors.New("connection refused"))
+		}
+	}
+
+	logger.WithError(errs[0]).Error("DB DOWN")
+	logger.WithError(errs[1]).Error("DB DOWN")
+	assert.Len(t, parseJSONLines(t, buf.String()), 1, "same fingerprint without lines")
+
+	GetAdvancedFormatter(formatter).Flags |= FlagErrorFingerprintLines
+	buf.Reset()
+	logger.WithError(errs[0]).Error("DB DOWN")
+	logger.WithError(errs[1]).Error("DB DOWN")
+	assert.Len(t, parseJSONLines(t, buf.String()), 2, "fingerprint by the wrapped formatter, with lines")
+}
+
+func TestDedupFormatter_SuppressedField(t *testing.T) {
+	logger, _, buf := newDedupLogger(time.Minute)
+	ts := time.Unix(1571170345, 0)
+
+	for i := 0; i < 3; i++ {
+		logger.WithError(newDedupError(i)).WithField(KeyDedupSuppressed, "caller").WithTime(ts).Error("DB DOWN")
+	}
+	assert.Len(t, parseJSONLines(t, buf.String()), 1, "caller field does not bypass suppression")
+}
@@ -0,0 +1,84 @@
+package errfmt
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ECSVersion is the implemented Elastic Common Schema version
+	ECSVersion = "1.6.0"
+	// ECSLabelsKey is the default namespace of fields and error details (values are strings)
+	ECSLabelsKey = "labels"
+)
+
+/*
+NewECSLogger builds a Logrus JSON logger with Elastic Common Schema field mapping
+	See ECSProfile
+*/
+func NewECSLogger(level log.Level, flags int, callStackSkipLast int,
+) *log.Logger {
+	logger := NewJSONLogger(level, flags, callStackSkipLast)
+	if f, ok := logger.Formatter.(*AdvancedJSONFormatter); ok {
+		f.Profile = ECSProfile
+	}
+
+	return logger
+}
+
+/*
+ECSProfile maps the entry to Elastic Common Schema (ECS) fields
+	@timestamp, log.level, message, ecs.version
+	error.message, error.type, error.stack_trace (see FlagCallStackInFields)
+	log.origin.function, log.origin.file.name, log.origin.file.line
+	Fields and error details (see FlagExtractDetails) are put under "labels" as strings,
+	or under DetailsKey (dot-separated path, like NestFields) with structure (if not empty)
+*/
+func ECSProfile(f *AdvancedJSONFormatter, entry *log.Entry) map[string]interface{} {
+	object := map[string]interface{}{
+		"@timestamp":  entry.Time.Format(time.RFC3339Nano),
+		"log.level":   entry.Level.String(),
+		"message":     entry.Message,
+		"ecs.version": ECSVersion,
+	}
+
+	if err := f.GetError(entry); err != nil {
+		object["error.message"] = err.Error()
+		object["error.type"] = ErrorType(err)
+		if (f.Flags & FlagCallStackInFields) > 0 {
+			if callStackLines := f.GetCallStack(entry); len(callStackLines) > 0 {
+				object["error.stack_trace"] = strings.Join(callStackLines, "\n")
+			}
+		}
+	}
+
+	if entry.HasCaller() {
+		object["log.origin.function"] = f.GetPackageTrimmer().TrimFunctionName(entry.Caller.Function)
+		object["log.origin.file.name"] = path.Base(entry.Caller.File)
+		object["log.origin.file.line"] = entry.Caller.Line
+	}
+
+	data := f.MergeDetailsToFields(entry)
+	delete(data, log.ErrorKey)
+	for key, value := range data {
+		if err, ok := value.(error); ok && err != nil {
+			data[key] = err.Error()
+		}
+	}
+	if len(data) > 0 {
+		if f.DetailsKey == "" {
+			labels := map[string]string{}
+			for key, value := range data {
+				labels[key] = logfmtValueString(value)
+			}
+			object[ECSLabelsKey] = labels
+		} else {
+			setFieldPath(object, strings.Split(f.DetailsKey, "."), data)
+		}
+	}
+
+	return object
+}
@@ -0,0 +1,124 @@
+package errfmt
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newECSLoggerMock(flags int, callStackSkipLast int) *LoggerMock {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewECSLogger(log.InfoLevel, flags, callStackSkipLast)
+	buf := new(bytes.Buffer)
+	loggerMock := &LoggerMock{
+		Logger:   logger,
+		outBuf:   buf,
+		exitCode: -1,
+	}
+	loggerMock.Out = buf
+	loggerMock.ExitFunc = loggerMock.exit
+
+	return loggerMock
+}
+
+func TestECS_WithError_CallStackInFields(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newECSLoggerMock(
+		FlagExtractDetails|FlagCallStackInFields,
+		2)
+	ts := time.Now()
+	tsRFC3339 := ts.Format(time.RFC3339Nano)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	formatter.PrettyPrint = true
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	// nolint:lll
+	assert.Equal(t, `{
+  "@timestamp": "`+tsRFC3339+`",
+  "ecs.version": "`+ECSVersion+`",
+  "error.message": "MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing \"NO_NUMBER\": invalid syntax",
+  "error.stack_trace": "errfmt.newWithDetails() errfmt.go:0\nerrfmt.GenerateDeepErrors() errfmt.go:0\n`+funcName+`() formatter_ecs_test.go:0",
+  "error.type": "*errors.errorString",
+  "labels": {
+    "K0_1": "V0_1",
+    "K0_2": "V0_2",
+    "K1_1": "V1_1",
+    "K1_2": "V1_2",
+    "K3 2": "V3 space",
+    "K3\"5": "V3\"doublequote",
+    "K3%6": "V3%percent",
+    "K3:3": "V3:column",
+    "K3;3": "V3;semicolumn",
+    "K3=1": "V3=equal",
+    "K5_bool": "true",
+    "K5_int": "12",
+    "K5_map": "{\"1\":\"ONE\",\"2\":\"TWO\"}",
+    "K5_struct": "{\"Text\":\"text\",\"Integer\":42,\"Bool\":true}"
+  },
+  "log.level": "error",
+  "log.origin.file.line": 0,
+  "log.origin.file.name": "formatter_ecs_test.go",
+  "log.origin.function": "`+funcName+`",
+  "message": "USER MSG"
+}
+`, replaceECSLine(replaceCallLine(loggerMock.outBuf.String())))
+}
+
+func TestECS_DetailsKey(t *testing.T) {
+	loggerMock := newECSLoggerMock(FlagNone, 0)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	formatter.DetailsKey = "custom"
+	entry := loggerMock.WithField("K5_map", map[string]int{"ONE": 1})
+	entry.Message = "USER MSG"
+
+	object := ECSProfile(formatter, entry)
+	assert.Equal(t, log.Fields{"K5_map": map[string]int{"ONE": 1}}, object["custom"])
+	assert.NotContains(t, object, ECSLabelsKey)
+	assert.NotContains(t, object, "error.message")
+	assert.Equal(t, "USER MSG", object["message"])
+}
+
+func TestECS_DetailsKey_Path(t *testing.T) {
+	loggerMock := newECSLoggerMock(FlagNone, 0)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	formatter.DetailsKey = "error.details"
+	entry := loggerMock.WithField("K", "V")
+
+	object := ECSProfile(formatter, entry)
+	assert.Equal(t, map[string]interface{}{"details": log.Fields{"K": "V"}}, object["error"])
+	assert.NotContains(t, object, "error.details")
+}
+
+func TestECS_ErrorField(t *testing.T) {
+	loggerMock := newECSLoggerMock(FlagNone, 0)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	entry := loggerMock.WithField("cause", errors.New("MESSAGE"))
+
+	object := ECSProfile(formatter, entry)
+	assert.Equal(t, map[string]string{"cause": "MESSAGE"}, object[ECSLabelsKey])
+
+	formatter.DetailsKey = "error.details"
+	object = ECSProfile(formatter, entry)
+	assert.Equal(t, map[string]interface{}{"details": log.Fields{"cause": "MESSAGE"}}, object["error"])
+}
+
+func replaceECSLine(text string) string {
+	return regexp.MustCompile(`"log.origin.file.line": \d+`).ReplaceAllString(text, `"log.origin.file.line": 0`)
+}
@@ -0,0 +1,115 @@
+package errfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// GCPKeySourceLocation is the source location key of Google Cloud Logging
+	GCPKeySourceLocation = "logging.googleapis.com/sourceLocation"
+	// GCPKeyLabels is the labels key of Google Cloud Logging
+	GCPKeyLabels = "logging.googleapis.com/labels"
+	// GCPTypeErrorEvent is the @type of Google Cloud Error Reporting events
+	GCPTypeErrorEvent = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+)
+
+/*
+NewGCPLogger builds a Logrus JSON logger with Google Cloud Logging / Error Reporting output
+	See GCPProfile
+*/
+func NewGCPLogger(level log.Level, flags int, callStackSkipLast int,
+) *log.Logger {
+	logger := NewJSONLogger(level, flags, callStackSkipLast)
+	if f, ok := logger.Formatter.(*AdvancedJSONFormatter); ok {
+		f.Profile = GCPProfile
+	}
+
+	return logger
+}
+
+// DefaultLevelToGCPSeverity maps logrus levels to Google Cloud Logging LogSeverity
+func DefaultLevelToGCPSeverity() map[log.Level]string {
+	return map[log.Level]string{
+		log.PanicLevel: "ALERT",
+		log.FatalLevel: "CRITICAL",
+		log.ErrorLevel: "ERROR",
+		log.WarnLevel:  "WARNING",
+		log.InfoLevel:  "INFO",
+		log.DebugLevel: "DEBUG",
+		log.TraceLevel: "DEBUG",
+	}
+}
+
+/*
+GCPProfile maps the entry to Google Cloud Logging structured log and Error Reporting fields
+	severity, message, timestamp, logging.googleapis.com/sourceLocation (caller)
+	logging.googleapis.com/labels: fields and error details (see FlagExtractDetails) as strings
+	If the entry has error: @type ReportedErrorEvent, context.reportLocation and
+	stack_trace in Go panic-like format (see FlagCallStackInFields)
+*/
+func GCPProfile(f *AdvancedJSONFormatter, entry *log.Entry) map[string]interface{} {
+	object := map[string]interface{}{
+		"severity":  DefaultLevelToGCPSeverity()[entry.Level],
+		"message":   entry.Message,
+		"timestamp": entry.Time.Format(time.RFC3339Nano),
+	}
+
+	if entry.HasCaller() {
+		object[GCPKeySourceLocation] = map[string]interface{}{
+			"file":     entry.Caller.File,
+			"line":     strconv.Itoa(entry.Caller.Line),
+			"function": entry.Caller.Function,
+		}
+	}
+
+	data := f.MergeDetailsToFields(entry)
+	delete(data, log.ErrorKey)
+	if len(data) > 0 {
+		labels := map[string]string{}
+		for key, value := range data {
+			if err, ok := value.(error); ok && err != nil {
+				value = err.Error()
+			}
+			labels[key] = logfmtValueString(value)
+		}
+		object[GCPKeyLabels] = labels
+	}
+
+	if err := f.GetError(entry); err != nil {
+		object["@type"] = GCPTypeErrorEvent
+		if entry.Message == "" {
+			object["message"] = err.Error()
+		} else {
+			object["message"] = entry.Message + ": " + err.Error()
+		}
+
+		if entry.HasCaller() {
+			object["context"] = map[string]interface{}{
+				"reportLocation": map[string]interface{}{
+					"filePath":     entry.Caller.File,
+					"lineNumber":   entry.Caller.Line,
+					"functionName": entry.Caller.Function,
+				},
+			}
+		}
+
+		if (f.Flags & FlagCallStackInFields) > 0 {
+			if callStackFrames := f.GetCallStackFrames(entry); len(callStackFrames) > 0 {
+				object["stack_trace"] = buildGoPanicStackTrace(err.Error(), callStackFrames, f.GetPackageTrimmer())
+			}
+		}
+	}
+
+	return object
+}
+
+// buildGoPanicStackTrace renders the call stack like a Go panic (recognized by Error Reporting), see GoPanicCallStackRenderer
+func buildGoPanicStackTrace(message string, callStackFrames []CallStackFrame, trimmer *PackageTrimmer) string {
+	return fmt.Sprintf("panic: %s\n\n%s\n",
+		message, strings.Join(GoPanicCallStackRenderer().Render(callStackFrames, trimmer), "\n"))
+}
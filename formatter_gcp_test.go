@@ -0,0 +1,99 @@
+package errfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newGCPLoggerMock(flags int, callStackSkipLast int) *LoggerMock {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewGCPLogger(log.InfoLevel, flags, callStackSkipLast)
+	buf := new(bytes.Buffer)
+	loggerMock := &LoggerMock{
+		Logger:   logger,
+		outBuf:   buf,
+		exitCode: -1,
+	}
+	loggerMock.Out = buf
+	loggerMock.ExitFunc = loggerMock.exit
+
+	return loggerMock
+}
+
+func TestGCP_WithError_CallStackInFields(t *testing.T) {
+	funcName := FunctionName()
+	loggerMock := newGCPLoggerMock(
+		FlagExtractDetails|FlagCallStackInFields,
+		2)
+	ts := time.Now()
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	object := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(loggerMock.outBuf.Bytes(), &object))
+
+	assert.Equal(t, "ERROR", object["severity"])
+	assert.Equal(t, "USER MSG: "+err.Error(), object["message"])
+	assert.Equal(t, ts.Format(time.RFC3339Nano), object["timestamp"])
+	assert.Equal(t, GCPTypeErrorEvent, object["@type"])
+	assert.Contains(t, object, GCPKeySourceLocation)
+	assert.Contains(t, object, "context")
+
+	labels, ok := object[GCPKeyLabels].(map[string]interface{})
+	assert.True(t, ok, GCPKeyLabels)
+	assert.Equal(t, "V0_1", labels["K0_1"])
+	assert.Equal(t, "12", labels["K5_int"])
+	assert.Equal(t, `{"1":"ONE","2":"TWO"}`, labels["K5_map"])
+
+	stackTrace, ok := object["stack_trace"].(string)
+	assert.True(t, ok, "stack_trace")
+	lines := strings.Split(replaceCallLine(stackTrace), "\n")
+	assert.Equal(t, "panic: "+err.Error(), lines[0])
+	assert.Equal(t, "", lines[1])
+	assert.Equal(t, "goroutine 1 [running]:", lines[2])
+	assert.Equal(t, "github.com/pgillich/errfmt.newWithDetails(...)", lines[3])
+	assert.True(t, strings.HasPrefix(lines[4], "\t/"), lines[4])
+	assert.Regexp(t, `/errfmt.go:0 \+0x[0-9a-f]+$`, lines[4])
+	assert.Equal(t, funcName+"(...)", lines[7])
+	assert.Regexp(t, `/formatter_gcp_test.go:0 \+0x[0-9a-f]+$`, lines[8])
+}
+
+func TestGCP_Info(t *testing.T) {
+	loggerMock := newGCPLoggerMock(FlagNone, 0)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	entry := loggerMock.WithField("K", "V")
+	entry.Level = log.WarnLevel
+	entry.Message = "USER MSG"
+
+	object := GCPProfile(formatter, entry)
+	assert.Equal(t, "WARNING", object["severity"])
+	assert.Equal(t, "USER MSG", object["message"])
+	assert.Equal(t, map[string]string{"K": "V"}, object[GCPKeyLabels])
+	assert.NotContains(t, object, "@type")
+	assert.NotContains(t, object, "stack_trace")
+}
+
+func TestGCP_ErrorField(t *testing.T) {
+	loggerMock := newGCPLoggerMock(FlagNone, 0)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	entry := loggerMock.WithField("cause", errors.New("MESSAGE"))
+
+	object := GCPProfile(formatter, entry)
+	assert.Equal(t, map[string]string{"cause": "MESSAGE"}, object[GCPKeyLabels])
+}
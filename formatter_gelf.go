@@ -0,0 +1,155 @@
+package errfmt
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/juju/rfc/rfc5424"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// GELFVersion is the implemented GELF version
+	GELFVersion = "1.1"
+)
+
+/*
+NewGELFLogger builds a Logrus logger + GELF (Graylog) formatter
+	host is os.Hostname(), if empty
+*/
+func NewGELFLogger(level log.Level, flags int, callStackSkipLast int, host string,
+) *log.Logger {
+	logger := log.New()
+
+	logger.Formatter = NewAdvancedGELFFormatter(flags, callStackSkipLast, host)
+	logger.Level = level
+	logger.ReportCaller = true
+
+	return logger
+}
+
+/*
+AdvancedGELFFormatter is a GELF 1.1 (Graylog) JSON formatter
+	Features:
+	* short_message: entry.Message (or the error, if empty)
+	* full_message: error and call stack (see FlagCallStackInFields and FlagCallStackOnConsole)
+	* level: syslog severity by LevelToSeverity
+	* Additional fields ("_" prefix) from fields and error details (see FlagExtractDetails),
+	  clashing names (after FixGELFFieldName) get a numeric suffix, by key order
+*/
+type AdvancedGELFFormatter struct {
+	AdvancedFormatter
+	LevelToSeverity map[log.Level]rfc5424.Severity
+	Host            string
+}
+
+// NewAdvancedGELFFormatter makes a new AdvancedGELFFormatter, host is os.Hostname(), if empty
+func NewAdvancedGELFFormatter(flags int, callStackSkipLast int, host string) *AdvancedGELFFormatter {
+	if host == "" {
+		host, _ = os.Hostname() // nolint:errcheck
+	}
+
+	return &AdvancedGELFFormatter{
+		AdvancedFormatter: AdvancedFormatter{
+			Flags:             flags,
+			CallStackSkipLast: callStackSkipLast,
+		},
+		LevelToSeverity: DefaultLevelToSeverity(),
+		Host:            host,
+	}
+}
+
+// Format implements logrus.Formatter interface
+func (f *AdvancedGELFFormatter) Format(entry *log.Entry) ([]byte, error) {
+	entry = f.CheckStack(entry)
+	data := f.PrepareFields(entry, f.GetClashingFields())
+	delete(data, log.FieldKeyLevel)
+	delete(data, KeyCallStack)
+
+	message := map[string]interface{}{
+		"version":   GELFVersion,
+		"host":      f.Host,
+		"timestamp": float64(entry.Time.UnixNano()/1e6) / 1e3,
+		"level":     int(f.LevelToSeverity[entry.Level]),
+	}
+
+	shortMessage := entry.Message
+	if err := f.GetError(entry); err != nil {
+		fullMessage := err.Error()
+		if (f.Flags & (FlagCallStackInFields | FlagCallStackOnConsole)) > 0 {
+			if callStackLines := f.GetCallStack(entry); len(callStackLines) > 0 {
+				fullMessage += "\n\t" + strings.Join(callStackLines, "\n\t")
+			}
+		}
+		message["full_message"] = fullMessage
+		if shortMessage == "" {
+			shortMessage = err.Error()
+		}
+	}
+	message["short_message"] = shortMessage
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := "_" + FixGELFFieldName(key)
+		for i := 2; message[name] != nil; i++ {
+			name = fmt.Sprintf("_%s_%d", FixGELFFieldName(key), i)
+		}
+		message[name] = gelfFieldValue(data[key])
+	}
+
+	textPart, err := JSONMarshal(message, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(textPart, '\n'), nil
+}
+
+/*
+FixGELFFieldName converts to a valid GELF additional field name
+	Invalid characters are replaced to '_', "id" is renamed to "fields.id"
+*/
+func FixGELFFieldName(name string) string {
+	if name == "id" {
+		return "fields.id"
+	}
+	if strings.IndexFunc(name, isInvalidGELFFieldNameRune) < 0 {
+		return name
+	}
+
+	str := strings.Builder{}
+	for _, b := range []byte(name) {
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' || b == '.' || b == '-' {
+			str.WriteByte(b) //nolint:gosec
+		} else {
+			str.WriteByte('_') //nolint:gosec
+		}
+	}
+
+	return str.String()
+}
+
+// isInvalidGELFFieldNameRune returns true, if the character is not allowed in additional field names
+func isInvalidGELFFieldNameRune(r rune) bool {
+	return !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.' || r == '-')
+}
+
+// gelfFieldValue keeps strings and numbers, renders others (see logfmtValueString)
+func gelfFieldValue(value interface{}) interface{} {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return value
+	}
+
+	return logfmtValueString(value)
+}
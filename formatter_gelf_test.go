@@ -0,0 +1,66 @@
+package errfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newGELFLoggerMock(flags int, callStackSkipLast int) *LoggerMock {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewGELFLogger(log.InfoLevel, flags, callStackSkipLast, "fqdn.host.com")
+	buf := new(bytes.Buffer)
+	loggerMock := &LoggerMock{
+		Logger:   logger,
+		outBuf:   buf,
+		exitCode: -1,
+	}
+	loggerMock.Out = buf
+	loggerMock.ExitFunc = loggerMock.exit
+
+	return loggerMock
+}
+
+func TestGELF_WithError_CallStackInFields(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newGELFLoggerMock(
+		FlagExtractDetails|FlagCallStackInFields,
+		2)
+	ts := time.Unix(1571170345, 123456789)
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).WithField("id", 42).Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	// nolint:lll
+	assert.Equal(t, `{"_K0_1":"V0_1","_K0_2":"V0_2","_K1_1":"V1_1","_K1_2":"V1_2","_K3_1":"V3=equal","_K3_2":"V3 space","_K3_3":"V3:column","_K3_3_2":"V3;semicolumn","_K3_5":"V3\"doublequote","_K3_6":"V3%percent","_K5_bool":"true","_K5_int":12,"_K5_map":"{\"1\":\"ONE\",\"2\":\"TWO\"}","_K5_struct":"{\"Text\":\"text\",\"Integer\":42,\"Bool\":true}","_error":"MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing \"NO_NUMBER\": invalid syntax","_fields.id":42,"_file":"formatter_gelf_test.go:0","_func":"`+funcName+`","full_message":"MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing \"NO_NUMBER\": invalid syntax\n\terrfmt.newWithDetails() errfmt.go:0\n\terrfmt.GenerateDeepErrors() errfmt.go:0\n\t`+funcName+`() formatter_gelf_test.go:0","host":"fqdn.host.com","level":3,"short_message":"USER MSG","timestamp":1571170345.123,"version":"1.1"}
+`, replaceCallLine(loggerMock.outBuf.String()))
+}
+
+func TestGELF_Info(t *testing.T) {
+	loggerMock := newGELFLoggerMock(FlagNone, 0)
+
+	loggerMock.WithField("K", true).Info("USER MSG")
+
+	message := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(loggerMock.outBuf.Bytes(), &message))
+	assert.Equal(t, "USER MSG", message["short_message"])
+	assert.Equal(t, float64(5), message["level"])
+	assert.Equal(t, "true", message["_K"])
+	assert.NotContains(t, message, "full_message")
+}
+
+func TestFixGELFFieldName(t *testing.T) {
+	assert.Equal(t, "fields.id", FixGELFFieldName("id"))
+	assert.Equal(t, "K3_2", FixGELFFieldName("K3 2"))
+	assert.Equal(t, "a.b-c_d", FixGELFFieldName("a.b-c_d"))
+}
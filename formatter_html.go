@@ -51,6 +51,18 @@ func GetAdvancedFormatter(formatter log.Formatter) *AdvancedFormatter {
 		return &f.AdvancedFormatter
 	case *AdvancedJSONFormatter:
 		return &f.AdvancedFormatter
+	case *AdvancedLogfmtFormatter:
+		return &f.AdvancedFormatter
+	case *AdvancedConsoleFormatter:
+		return &f.AdvancedFormatter
+	case *AdvancedGELFFormatter:
+		return &f.AdvancedFormatter
+	case *AdvancedCEFFormatter:
+		return &f.AdvancedFormatter
+	case *DedupFormatter:
+		return GetAdvancedFormatter(f.Formatter)
+	case *AsyncWriterFormatter:
+		return GetAdvancedFormatter(f.Formatter)
 	}
 	return nil
 }
@@ -59,6 +71,7 @@ func GetAdvancedFormatter(formatter log.Formatter) *AdvancedFormatter {
 // nolint:golint,gocyclo,funlen
 func BuildHTTPProblem(statusCode int, entry *log.Entry) *HTTPProblem {
 	f := GetAdvancedFormatter(entry.Logger.Formatter)
+	entry = f.CheckStack(entry)
 	data := f.PrepareFields(entry, GetClashingFieldsHTTP())
 
 	if entry.Time.IsZero() {
@@ -68,9 +81,12 @@ func BuildHTTPProblem(statusCode int, entry *log.Entry) *HTTPProblem {
 	}
 
 	callStack := []string{}
-	callStackLines := f.GetCallStack(entry)
+	var callStackLinks []CallStackLink
 	if (f.Flags & FlagCallStackInHTTPProblem) > 0 {
-		callStack = callStackLines
+		callStack = f.GetCallStackWithSource(entry)
+		if f.SourceURLTemplate != "" {
+			callStackLinks = f.GetCallStackLinks(entry)
+		}
 	}
 
 	title := http.StatusText(statusCode)
@@ -100,13 +116,16 @@ func BuildHTTPProblem(statusCode int, entry *log.Entry) *HTTPProblem {
 		detail = fmt.Sprintf("%s", msg)
 	}
 
-	return NewHTTPProblem(
+	httpProblem := NewHTTPProblem(
 		statusCode,
 		title,
 		detail,
 		details,
 		callStack,
 	)
+	httpProblem.CallStackLinks = callStackLinks
+
+	return httpProblem
 }
 
 // RenderHTTPProblem renders HTTPProblem a JSON
@@ -134,6 +153,8 @@ type HTTPProblem struct {
 	problems.DefaultProblem
 	Details   map[string]string `json:"details,omitempty"`
 	CallStack []string          `json:"callstack,omitempty"`
+	// CallStackLinks is the call stack with source URLs (see AdvancedFormatter.SourceURLTemplate)
+	CallStackLinks []CallStackLink `json:"callstack_links,omitempty"`
 }
 
 // NewHTTPProblem makes a HTTPProblem instance
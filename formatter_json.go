@@ -1,6 +1,11 @@
 package errfmt
 
 import (
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"emperror.dev/errors/utils/keyval"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -9,7 +14,7 @@ NewJSONLogger builds a customized Logrus JSON logger+formatter
 	Features:
 	* CallStackSkipLast
 	* CallStackNewLines (only CallStackInFields)
-	* ModuleCallerPrettyfier
+	* AdvancedFormatter.TrimCaller (see PackageTrimmer)
 */
 func NewJSONLogger(level log.Level, flags int, callStackSkipLast int,
 ) *log.Logger {
@@ -25,38 +30,167 @@ func NewJSONLogger(level log.Level, flags int, callStackSkipLast int,
 /*
 AdvancedJSONFormatter is a customized Logrus JSON formatter
 	Features:
-	* ModuleCallerPrettyfier
+	* AdvancedFormatter.TrimCaller (see PackageTrimmer)
+	* NestedJSON: errors as objects, details with structure (optionally under DetailsKey)
+	* Profile: output structure for log collectors (for example ECSProfile)
 */
 type AdvancedJSONFormatter struct {
 	log.JSONFormatter
 	AdvancedFormatter
+	// DetailsKey is the dot-separated path of extracted details, if FlagNestedJSON is set (top level, if empty)
+	DetailsKey string
+	// Profile builds the output object, instead of logrus.JSONFormatter (if not nil)
+	Profile JSONProfile
 }
 
+// JSONProfile builds the output object of AdvancedJSONFormatter
+type JSONProfile func(f *AdvancedJSONFormatter, entry *log.Entry) map[string]interface{}
+
 // NewAdvancedJSONFormatter makes a new AdvancedJSONFormatter
 func NewAdvancedJSONFormatter(flags int, callStackSkipLast int) *AdvancedJSONFormatter {
-	return &AdvancedJSONFormatter{
-		JSONFormatter: log.JSONFormatter{
-			CallerPrettyfier: ModuleCallerPrettyfier,
-		},
+	formatter := &AdvancedJSONFormatter{
 		AdvancedFormatter: AdvancedFormatter{
 			Flags:             flags,
 			CallStackSkipLast: callStackSkipLast,
 		},
 	}
+	formatter.JSONFormatter.CallerPrettyfier = formatter.TrimCaller
+
+	return formatter
 }
 
 // Format implements logrus.Formatter interface
 func (f *AdvancedJSONFormatter) Format(entry *log.Entry) ([]byte, error) {
-	entry.Data = f.MergeDetailsToFields(entry)
+	entry = f.CheckStack(entry)
+	if f.Profile != nil {
+		return f.FormatProfile(entry)
+	}
+
 	callStackLines := f.GetCallStack(entry)
-	if (f.Flags & FlagCallStackInFields) > 0 {
-		entry.Data[KeyCallStack] = callStackLines
+	var callStackLinks []CallStackLink
+	if f.SourceURLTemplate != "" && (f.Flags&FlagCallStackInFields) > 0 {
+		callStackLinks = f.GetCallStackLinks(entry)
+	}
+	var data log.Fields
+	if (f.Flags & FlagNestedJSON) > 0 {
+		data = f.NestFields(entry)
+	} else {
+		data = f.MergeDetailsToFields(entry)
+	}
+	if !f.IsStackSuppressed(entry) {
+		if callStackLinks != nil {
+			data[KeyCallStack] = callStackLinks
+		} else if (f.Flags & FlagCallStackInFields) > 0 {
+			data[KeyCallStack] = callStackLines
+		}
 	}
 
-	textPart, err := f.JSONFormatter.Format(entry)
+	textPart, err := f.JSONFormatter.Format(CopyEntry(entry, data))
 
 	if (f.Flags & FlagCallStackOnConsole) > 0 {
 		textPart = f.AppendCallStack(textPart, callStackLines)
 	}
 	return textPart, err
 }
+
+// FormatProfile renders the object built by Profile
+func (f *AdvancedJSONFormatter) FormatProfile(entry *log.Entry) ([]byte, error) {
+	indent := ""
+	if f.PrettyPrint {
+		indent = "  "
+	}
+	textPart, err := JSONMarshal(f.Profile(f, entry), indent, true)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to marshal fields to JSON")
+	}
+	textPart = append(textPart, '\n')
+
+	if (f.Flags & FlagCallStackOnConsole) > 0 {
+		textPart = f.AppendCallStack(textPart, f.GetCallStack(entry))
+	}
+	return textPart, nil
+}
+
+/*
+NestFields copies entry.Data and converts errors to objects (see ErrorToObject)
+	Extracted details are put under DetailsKey, if FlagExtractDetails is set
+*/
+func (f *AdvancedJSONFormatter) NestFields(entry *log.Entry) log.Fields {
+	data := log.Fields{}
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok && err != nil {
+			data[k] = ErrorToObject(err)
+		} else {
+			data[k] = v
+		}
+	}
+
+	if (f.Flags & FlagExtractDetails) > 0 {
+		if err := f.GetError(entry); err != nil {
+			details := errorDetailsToMap(err)
+			if f.DetailsKey == "" {
+				for k, v := range details {
+					data[k] = v
+				}
+			} else if len(details) > 0 {
+				setFieldPath(data, strings.Split(f.DetailsKey, "."), details)
+			}
+		}
+	}
+	f.AddFingerprint(data, f.GetError(entry))
+	f.AddStackID(data, entry)
+
+	return data
+}
+
+/*
+ErrorToObject converts an error to a JSON-friendly object
+	message: Error()
+	type: type of the root cause
+	details: errors.GetDetails() with structure (omitted, if empty)
+*/
+func ErrorToObject(err error) map[string]interface{} {
+	object := map[string]interface{}{
+		"message": err.Error(),
+		"type":    ErrorType(err),
+	}
+	if details := errorDetailsToMap(err); len(details) > 0 {
+		object["details"] = details
+	}
+
+	return object
+}
+
+// ErrorType returns the type name of the root cause
+func ErrorType(err error) string {
+	return fmt.Sprintf("%T", errors.Cause(err))
+}
+
+// errorDetailsToMap returns the error details, errors in details are converted to string
+func errorDetailsToMap(err error) map[string]interface{} {
+	details := keyval.ToMap(errors.GetDetails(err))
+	for k, v := range details {
+		if detailErr, ok := v.(error); ok && detailErr != nil {
+			details[k] = detailErr.Error()
+		}
+	}
+
+	return details
+}
+
+/*
+setFieldPath sets the value by the path, intermediate objects are created or extended
+	Clashing non-object values are renamed by prefixFieldClashes
+*/
+func setFieldPath(data map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := data[key].(map[string]interface{})
+		if !ok {
+			prefixFieldClashes(data, key)
+			child = map[string]interface{}{}
+			data[key] = child
+		}
+		data = child
+	}
+	data[path[len(path)-1]] = value
+}
@@ -2,12 +2,14 @@ package errfmt
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"emperror.dev/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -131,3 +133,81 @@ func TestLogrus_JSONLogger_CallStackInFields(t *testing.T) {
 `, replaceCallLine(loggerMock.outBuf.String()))
 	}
 }
+
+func TestLogrus_JSONLogger_NestedJSON(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newJSONLoggerMock(
+		FlagExtractDetails|FlagNestedJSON,
+		2)
+	ts := time.Now()
+	tsRFC3339 := ts.Format(time.RFC3339)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	formatter.PrettyPrint = true
+	formatter.DetailsKey = "error.details"
+
+	err := errors.WithDetails(errors.NewWithDetails("MESSAGE", "K1", "V1"),
+		"K2_int", 12,
+		"K2_map", map[int]string{1: "ONE"},
+		"K2_error", errors.New("DETAIL ERROR"),
+	)
+	loggerMock.WithError(err).WithTime(ts).WithField("STR", "str").Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	assert.Equal(t, `{
+  "STR": "str",
+  "error": {
+    "details": {
+      "K1": "V1",
+      "K2_error": "DETAIL ERROR",
+      "K2_int": 12,
+      "K2_map": {
+        "1": "ONE"
+      }
+    },
+    "message": "MESSAGE",
+    "type": "*errors.plainError"
+  },
+  "file": "formatter_json_test.go:0",
+  "func": "`+funcName+`",
+  "level": "error",
+  "msg": "USER MSG",
+  "time": "`+tsRFC3339+`"
+}
+`, replaceCallLine(loggerMock.outBuf.String()))
+}
+
+func TestErrorToObject(t *testing.T) {
+	object := ErrorToObject(GenerateDeepErrors())
+	assert.Equal(t, "*errors.errorString", object["type"]) // strconv.ErrSyntax
+	assert.Equal(t, GenerateDeepErrors().Error(), object["message"])
+	details, ok := object["details"].(map[string]interface{})
+	assert.True(t, ok, "details")
+	assert.Equal(t, map[int]string{1: "ONE", 2: "TWO"}, details["K5_map"])
+
+	assert.NotContains(t, ErrorToObject(errors.NewPlain("PLAIN")), "details")
+}
+
+func TestSetFieldPath(t *testing.T) {
+	data := log.Fields{"a": "scalar"}
+	setFieldPath(data, []string{"a", "b", "c"}, 1)
+	setFieldPath(data, []string{"a", "d"}, 2)
+	assert.Equal(t, log.Fields{
+		"fields.a": "scalar",
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{"c": 1},
+			"d": 2,
+		},
+	}, data)
+}
+
+func TestJSON_NestedJSON_CallStackInFields(t *testing.T) {
+	loggerMock := newJSONLoggerMock(FlagNestedJSON|FlagCallStackInFields, 2)
+	loggerMock.WithError(GenerateDeepErrors()).Error("USER MSG")
+
+	message := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(loggerMock.outBuf.Bytes(), &message))
+	assert.Len(t, message[KeyCallStack], 3)
+}
@@ -0,0 +1,162 @@
+package errfmt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+NewLogfmtLogger builds a customized Logrus logger + strict logfmt formatter
+	Features:
+	* CallStackSkipLast
+	* CallStackOnConsole and CallStackInFields
+	* ModuleCallerPrettyfier
+	* PrintStructFieldNames
+*/
+func NewLogfmtLogger(level log.Level, flags int, callStackSkipLast int,
+) *log.Logger {
+	logger := log.New()
+
+	logger.Formatter = NewAdvancedLogfmtFormatter(flags, callStackSkipLast)
+	logger.Level = level
+	logger.ReportCaller = true
+
+	return logger
+}
+
+/*
+AdvancedLogfmtFormatter is a strict logfmt formatter
+	Features:
+	* Keys are fixed by FixStructuredDataName
+	* Values are quoted by LogfmtValue, if needed
+	* Non-scalar values are rendered in JSON (or by "%+v", see FlagPrintStructFieldNames)
+	* AdvancedFieldOrder
+*/
+type AdvancedLogfmtFormatter struct {
+	AdvancedFormatter
+	// TimestampFormat is time.RFC3339, if empty
+	TimestampFormat string
+	SortingFunc     func([]string)
+}
+
+// NewAdvancedLogfmtFormatter makes a new AdvancedLogfmtFormatter
+func NewAdvancedLogfmtFormatter(flags int, callStackSkipLast int) *AdvancedLogfmtFormatter {
+	return &AdvancedLogfmtFormatter{
+		AdvancedFormatter: AdvancedFormatter{
+			Flags:             flags,
+			CallStackSkipLast: callStackSkipLast,
+		},
+		TimestampFormat: time.RFC3339,
+		SortingFunc:     SortingFuncDecorator(AdvancedFieldOrder()),
+	}
+}
+
+// Format implements logrus.Formatter interface
+func (f *AdvancedLogfmtFormatter) Format(entry *log.Entry) ([]byte, error) {
+	entry = f.CheckStack(entry)
+	data := f.PrepareFields(entry, f.GetClashingFields())
+	callStackLines := f.GetCallStack(entry)
+
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = time.RFC3339
+	}
+	data[log.FieldKeyTime] = entry.Time.Format(timestampFormat)
+	data[log.FieldKeyMsg] = entry.Message
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	f.SortingFunc(keys)
+
+	textPart := []byte{}
+	for i, key := range keys {
+		if i > 0 {
+			textPart = append(textPart, ' ')
+		}
+		textPart = append(textPart, FixStructuredDataName(key)...)
+		textPart = append(textPart, '=')
+		textPart = append(textPart, LogfmtValue(logfmtValueString(data[key]))...)
+	}
+	textPart = append(textPart, '\n')
+
+	if (f.Flags & FlagCallStackOnConsole) > 0 {
+		textPart = f.AppendCallStack(textPart, callStackLines)
+	}
+
+	return textPart, nil
+}
+
+// logfmtValueString renders scalars by "%v", others in JSON
+func logfmtValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	if kind := reflect.ValueOf(value).Kind(); kind == reflect.Bool || IsNumeric(kind) {
+		return fmt.Sprintf("%v", value)
+	}
+
+	bytes, err := JSONMarshal(value, "", false)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(bytes)
+}
+
+/*
+LogfmtValue quotes the value, if it's empty or contains space, '=', '"', '\' or control character
+	Escapes '"', '\', '\n', '\r', '\t' and other control characters (\u00XX).
+*/
+func LogfmtValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " =\"\\") && !hasControlChar(value) {
+		return value
+	}
+
+	str := strings.Builder{}
+	str.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			str.WriteByte('\\')
+			str.WriteRune(r)
+		case '\n':
+			str.WriteString(`\n`)
+		case '\r':
+			str.WriteString(`\r`)
+		case '\t':
+			str.WriteString(`\t`)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(&str, `\u%04x`, r)
+			} else {
+				str.WriteRune(r)
+			}
+		}
+	}
+	str.WriteByte('"')
+
+	return str.String()
+}
+
+// hasControlChar returns true, if value contains control character or invalid UTF-8
+func hasControlChar(value string) bool {
+	for _, r := range value {
+		if r < ' ' || r == 0x7f || r == utf8.RuneError {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package errfmt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newLogfmtLoggerMock(flags int, callStackSkipLast int) *LoggerMock {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewLogfmtLogger(log.InfoLevel, flags, callStackSkipLast)
+	buf := new(bytes.Buffer)
+	loggerMock := &LoggerMock{
+		Logger:   logger,
+		outBuf:   buf,
+		exitCode: -1,
+	}
+	loggerMock.Out = buf
+	loggerMock.ExitFunc = loggerMock.exit
+
+	return loggerMock
+}
+
+type logfmtPair struct {
+	key   string
+	value string
+}
+
+// parseLogfmt is a strict logfmt parser: key=value or key="quoted value", separated by one space
+func parseLogfmt(line string) ([]logfmtPair, error) {
+	pairs := []logfmtPair{}
+	for len(line) > 0 {
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 || strings.ContainsAny(line[:eq], " \"") {
+			return nil, fmt.Errorf("invalid key at %q", line)
+		}
+		pair := logfmtPair{key: line[:eq]}
+		line = line[eq+1:]
+
+		if strings.HasPrefix(line, `"`) {
+			end := 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated value of %s", pair.key)
+			}
+			value, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			pair.value = value
+			line = line[end+1:]
+		} else {
+			end := strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			pair.value = line[:end]
+			if pair.value == "" || strings.ContainsAny(pair.value, "=\"\\") {
+				return nil, fmt.Errorf("invalid unquoted value of %s", pair.key)
+			}
+			line = line[end:]
+		}
+
+		if len(line) > 0 {
+			if line[0] != ' ' {
+				return nil, fmt.Errorf("missing space after %s", pair.key)
+			}
+			line = line[1:]
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
+
+func TestLogfmt_WithError_CallStackOnConsole(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newLogfmtLoggerMock(
+		FlagExtractDetails|FlagCallStackOnConsole,
+		2)
+	ts := time.Now()
+	tsRFC3339 := ts.Format(time.RFC3339)
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	// nolint:lll
+	assert.Equal(t, `level=error time=`+tsRFC3339+` func=`+funcName+` error="MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing \"NO_NUMBER\": invalid syntax" msg="USER MSG" file=formatter_logfmt_test.go:0 K0_1=V0_1 K0_2=V0_2 K1_1=V1_1 K1_2=V1_2 K3_2="V3 space" K3_5="V3\"doublequote" K3%6=V3%percent K3:3=V3:column K3;3=V3;semicolumn K3_1="V3=equal" K5_bool=true K5_int=12 K5_map="{\"1\":\"ONE\",\"2\":\"TWO\"}" K5_struct="{\"Text\":\"text\",\"Integer\":42,\"Bool\":true}"
+	errfmt.newWithDetails() errfmt.go:0
+	errfmt.GenerateDeepErrors() errfmt.go:0
+	`+funcName+`() formatter_logfmt_test.go:0
+`, replaceCallLine(loggerMock.outBuf.String()))
+}
+
+func TestLogfmt_RoundTrip(t *testing.T) {
+	loggerMock := newLogfmtLoggerMock(
+		FlagExtractDetails|FlagCallStackInFields,
+		2)
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithFields(log.Fields{
+		"empty":     "",
+		"multiline": "line1\nline2\ttab\x01",
+		"back\\":    `C:\path`,
+	}).Log(log.ErrorLevel, "USER MSG")
+
+	line := loggerMock.outBuf.String()
+	assert.True(t, strings.HasSuffix(line, "\n"))
+	assert.Equal(t, 1, strings.Count(line, "\n"))
+
+	pairs, parseErr := parseLogfmt(strings.TrimSuffix(line, "\n"))
+	assert.Nil(t, parseErr, line)
+
+	fields := map[string]string{}
+	for _, pair := range pairs {
+		fields[pair.key] = pair.value
+	}
+	assert.Equal(t, "error", fields[log.FieldKeyLevel])
+	assert.Equal(t, "USER MSG", fields[log.FieldKeyMsg])
+	assert.Equal(t, err.Error(), fields[log.ErrorKey])
+	assert.Equal(t, "V3 space", fields["K3_2"])
+	assert.Equal(t, `V3"doublequote`, fields["K3_5"])
+	assert.Equal(t, "V3=equal", fields["K3_1"])
+	assert.Equal(t, "", fields["empty"])
+	assert.Equal(t, "line1\nline2\ttab\x01", fields["multiline"])
+	assert.Equal(t, `C:\path`, fields["back\\"])
+	assert.True(t, strings.HasPrefix(fields[KeyCallStack], `["errfmt.newWithDetails() errfmt.go:`))
+}
+
+func TestLogfmtValue(t *testing.T) {
+	assert.Equal(t, `""`, LogfmtValue(""))
+	assert.Equal(t, `abc`, LogfmtValue("abc"))
+	assert.Equal(t, `"a b"`, LogfmtValue("a b"))
+	assert.Equal(t, `"a=b"`, LogfmtValue("a=b"))
+	assert.Equal(t, `"a\"b\\c"`, LogfmtValue(`a"b\c`))
+	assert.Equal(t, `"a\nb\u0000"`, LogfmtValue("a\nb\x00"))
+	assert.Equal(t, `árvíztűrő`, LogfmtValue("árvíztűrő"))
+}
@@ -0,0 +1,75 @@
+package errfmt
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+NewOTelLogger builds a Logrus JSON logger with OpenTelemetry Logs data model output
+	See OTelProfile
+*/
+func NewOTelLogger(level log.Level, flags int, callStackSkipLast int,
+) *log.Logger {
+	logger := NewJSONLogger(level, flags, callStackSkipLast)
+	if f, ok := logger.Formatter.(*AdvancedJSONFormatter); ok {
+		f.Profile = OTelProfile
+	}
+
+	return logger
+}
+
+// DefaultLevelToOTelSeverity maps logrus levels to OpenTelemetry SeverityNumber
+func DefaultLevelToOTelSeverity() map[log.Level]int {
+	return map[log.Level]int{
+		log.PanicLevel: 24, // FATAL4
+		log.FatalLevel: 21, // FATAL
+		log.ErrorLevel: 17, // ERROR
+		log.WarnLevel:  13, // WARN
+		log.InfoLevel:  9,  // INFO
+		log.DebugLevel: 5,  // DEBUG
+		log.TraceLevel: 1,  // TRACE
+	}
+}
+
+/*
+OTelProfile maps the entry to the OpenTelemetry Logs data model
+	Timestamp (nanoseconds since Unix epoch), SeverityNumber, SeverityText, Body, Attributes
+	Attributes: fields, error details (see FlagExtractDetails), code.* (caller),
+	exception.type, exception.message, exception.stacktrace (see FlagCallStackInFields)
+*/
+func OTelProfile(f *AdvancedJSONFormatter, entry *log.Entry) map[string]interface{} {
+	attributes := map[string]interface{}{}
+	for key, value := range f.MergeDetailsToFields(entry) {
+		if err, ok := value.(error); ok && err != nil {
+			value = err.Error()
+		}
+		attributes[key] = value
+	}
+	delete(attributes, log.ErrorKey)
+
+	if entry.HasCaller() {
+		attributes["code.function"] = f.GetPackageTrimmer().TrimFunctionName(entry.Caller.Function)
+		attributes["code.filepath"] = entry.Caller.File
+		attributes["code.lineno"] = entry.Caller.Line
+	}
+
+	if err := f.GetError(entry); err != nil {
+		attributes["exception.type"] = ErrorType(err)
+		attributes["exception.message"] = err.Error()
+		if (f.Flags & FlagCallStackInFields) > 0 {
+			if callStackLines := f.GetCallStack(entry); len(callStackLines) > 0 {
+				attributes["exception.stacktrace"] = strings.Join(callStackLines, "\n")
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"Timestamp":      entry.Time.UnixNano(),
+		"SeverityNumber": DefaultLevelToOTelSeverity()[entry.Level],
+		"SeverityText":   strings.ToUpper(entry.Level.String()),
+		"Body":           entry.Message,
+		"Attributes":     attributes,
+	}
+}
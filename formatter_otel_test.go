@@ -0,0 +1,89 @@
+package errfmt
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newOTelLoggerMock(flags int, callStackSkipLast int) *LoggerMock {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+
+	logger := NewOTelLogger(log.InfoLevel, flags, callStackSkipLast)
+	buf := new(bytes.Buffer)
+	loggerMock := &LoggerMock{
+		Logger:   logger,
+		outBuf:   buf,
+		exitCode: -1,
+	}
+	loggerMock.Out = buf
+	loggerMock.ExitFunc = loggerMock.exit
+
+	return loggerMock
+}
+
+func TestOTel_WithError_CallStackInFields(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newOTelLoggerMock(
+		FlagCallStackInFields,
+		2)
+	ts := time.Now()
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	formatter.PrettyPrint = true
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).WithField("K", map[string]int{"ONE": 1}).Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	// nolint:lll
+	assert.Equal(t, `{
+  "Attributes": {
+    "K": {
+      "ONE": 1
+    },
+    "code.filepath": "",
+    "code.function": "`+funcName+`",
+    "code.lineno": 0,
+    "exception.message": "MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing \"NO_NUMBER\": invalid syntax",
+    "exception.stacktrace": "errfmt.newWithDetails() errfmt.go:0\nerrfmt.GenerateDeepErrors() errfmt.go:0\n`+funcName+`() formatter_otel_test.go:0",
+    "exception.type": "*errors.errorString"
+  },
+  "Body": "USER MSG",
+  "SeverityNumber": 17,
+  "SeverityText": "ERROR",
+  "Timestamp": `+strconv.FormatInt(ts.UnixNano(), 10)+`
+}
+`, replaceOTelCode(replaceCallLine(loggerMock.outBuf.String())))
+}
+
+func TestOTel_ExtractDetails(t *testing.T) {
+	loggerMock := newOTelLoggerMock(FlagExtractDetails, 0)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedJSONFormatter)
+	assert.True(t, ok, "AdvancedJSONFormatter")
+	entry := loggerMock.WithError(GenerateDeepErrors())
+	entry.Level = log.WarnLevel
+
+	object := OTelProfile(formatter, entry)
+	assert.Equal(t, 13, object["SeverityNumber"])
+	attributes, ok := object["Attributes"].(map[string]interface{})
+	assert.True(t, ok, "Attributes")
+	assert.Equal(t, "V0_1", attributes["K0_1"])
+	assert.Equal(t, 12, attributes["K5_int"])
+	assert.NotContains(t, attributes, log.ErrorKey)
+	assert.NotContains(t, attributes, "exception.stacktrace")
+}
+
+func replaceOTelCode(text string) string {
+	text = regexp.MustCompile(`"code.lineno": \d+`).ReplaceAllString(text, `"code.lineno": 0`)
+	return regexp.MustCompile(`"code.filepath": "[^"]*"`).ReplaceAllString(text, `"code.filepath": ""`)
+}
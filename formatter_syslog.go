@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"emperror.dev/errors"
+	"emperror.dev/errors/utils/keyval"
 	"github.com/juju/rfc/rfc5424"
 	log "github.com/sirupsen/logrus"
 )
@@ -13,6 +15,13 @@ const (
 	StructuredIDDetails = "details"
 	// nolint:golint
 	StructuredIDCallStack = "calls"
+
+	// MsgIDDetails is the default MSGID, if call stack is not in fields
+	MsgIDDetails = "DETAILS_MSG"
+	// MsgIDDetailsCalls is the default MSGID, if call stack is in fields
+	MsgIDDetailsCalls = "DETAILS_CALLS_MSG"
+	// MsgIDMaxLength is the max. length of MSGID (RFC5424)
+	MsgIDMaxLength = 32
 )
 
 // nolint:golint
@@ -40,6 +49,23 @@ type AdvancedSyslogFormatter struct {
 	MsgID           rfc5424.MsgID
 	AdvancedFormatter
 	SortingFunc func([]string)
+	MsgIDRules
+}
+
+// MsgIDRules computes the message ID (syslog MSGID, CEF/LEEF event ID) of entries
+type MsgIDRules struct {
+	// MsgIDFunc computes MSGID from the entry, if returns non-empty
+	MsgIDFunc func(*log.Entry) string
+	// MsgIDDetailKey is the error details key of MSGID (for example error code)
+	MsgIDDetailKey string
+	// MsgIDErrors maps sentinel errors to MSGID, see RegisterMsgIDError
+	MsgIDErrors []MsgIDError
+}
+
+// MsgIDError is a sentinel error - MSGID pair
+type MsgIDError struct {
+	Err   error
+	MsgID string
 }
 
 // nolint:golint
@@ -66,13 +92,15 @@ func NewAdvancedSyslogFormatter(flags int, callStackSkipLast int,
 
 // Format implements logrus.Formatter interface
 func (f *AdvancedSyslogFormatter) Format(entry *log.Entry) ([]byte, error) { //nolint:funlen,gocyclo
+	entry = f.CheckStack(entry)
 	trimJSONDquote := (f.Flags & FlagTrimJSONDquote) > 0
 
 	data := f.PrepareFields(entry, f.GetClashingFields())
 	callStackLines := f.GetCallStack(entry)
 
 	detailList := NewJSONDataElement(StructuredIDDetails)
-	detailKeys := []string{}
+	detailList.params = make([]rfc5424.StructuredDataParam, 0, len(data))
+	detailKeys := make([]string, 0, len(data))
 	for key := range data {
 		if key != KeyCallStack {
 			detailKeys = append(detailKeys, key)
@@ -87,19 +115,16 @@ func (f *AdvancedSyslogFormatter) Format(entry *log.Entry) ([]byte, error) { //n
 		detailList,
 	}
 
-	msgIDdefault := "DETAILS_MSG"
-	if (f.Flags & FlagCallStackInFields) > 0 {
-		msgIDdefault = "DETAILS_CALLS_MSG"
+	msgIDdefault := MsgIDDetails
+	if (f.Flags&FlagCallStackInFields) > 0 && !f.IsStackSuppressed(entry) {
+		msgIDdefault = MsgIDDetailsCalls
 
 		callsList := NewJSONDataElement(StructuredIDCallStack)
 		callsList.Append(KeyCallStack, callStackLines, trimJSONDquote)
 
 		structuredData = append(structuredData, callsList)
 	}
-	msgID := f.MsgID
-	if msgID == "" {
-		msgID = rfc5424.MsgID(msgIDdefault)
-	}
+	msgID := f.GetMsgID(entry, msgIDdefault)
 
 	message := rfc5424.Message{
 		Header: rfc5424.Header{
@@ -127,45 +152,151 @@ func (f *AdvancedSyslogFormatter) Format(entry *log.Entry) ([]byte, error) { //n
 	return textPart, nil
 }
 
+// RegisterMsgIDError registers a sentinel error for MSGID (checked by errors.Is)
+func (r *MsgIDRules) RegisterMsgIDError(err error, msgID string) {
+	r.MsgIDErrors = append(r.MsgIDErrors, MsgIDError{Err: err, MsgID: msgID})
+}
+
+/*
+ResolveMsgID computes the message ID of the entry (not fixed), empty if no rule matches
+	Order: MsgIDFunc, MsgIDErrors, MsgIDDetailKey (error details, then fields)
+*/
+func (r *MsgIDRules) ResolveMsgID(entry *log.Entry, err error) string {
+	if r.MsgIDFunc != nil {
+		if msgID := r.MsgIDFunc(entry); msgID != "" {
+			return msgID
+		}
+	}
+
+	if err != nil {
+		for _, msgIDError := range r.MsgIDErrors {
+			if errors.Is(err, msgIDError.Err) {
+				return msgIDError.MsgID
+			}
+		}
+
+		if r.MsgIDDetailKey != "" {
+			if code, ok := keyval.ToMap(errors.GetDetails(err))[r.MsgIDDetailKey]; ok {
+				if msgID := fmt.Sprintf("%v", code); msgID != "" {
+					return msgID
+				}
+			}
+		}
+	}
+
+	if r.MsgIDDetailKey != "" {
+		if code, ok := entry.Data[r.MsgIDDetailKey]; ok {
+			return fmt.Sprintf("%v", code)
+		}
+	}
+
+	return ""
+}
+
+/*
+GetMsgID computes the MSGID of the entry
+	Order: MsgIDFunc, MsgIDErrors, MsgIDDetailKey, MsgID, msgIDdefault
+*/
+func (f *AdvancedSyslogFormatter) GetMsgID(entry *log.Entry, msgIDdefault string) rfc5424.MsgID {
+	if msgID := f.ResolveMsgID(entry, f.GetError(entry)); msgID != "" {
+		return rfc5424.MsgID(FixMsgID(msgID))
+	}
+
+	if f.MsgID != "" {
+		return f.MsgID
+	}
+
+	return rfc5424.MsgID(msgIDdefault)
+}
+
+// FixMsgID replaces invalid MSGID characters to '_' and truncates to MsgIDMaxLength
+func FixMsgID(msgID string) string {
+	str := strings.Builder{}
+
+	for _, b := range []byte(msgID) {
+		if str.Len() >= MsgIDMaxLength {
+			break
+		}
+		if b < '!' || b > '~' { // PRINTUSASCII
+			str.WriteByte('_') //nolint:gosec
+		} else {
+			str.WriteByte(b) //nolint:gosec
+		}
+	}
+
+	return str.String()
+}
+
 // nolint:golint
 func MessageString(m rfc5424.Message) string {
-	stringStructuredData := StructuredDataString(m.StructuredData)
-	if m.Msg == "" {
-		return fmt.Sprintf("%s %s", m.Header, stringStructuredData)
+	text := strings.Builder{}
+	text.WriteString(m.Header.String())
+	text.WriteByte(' ')
+	writeStructuredData(&text, m.StructuredData)
+	if m.Msg != "" {
+		text.WriteByte(' ')
+		text.WriteString(m.Msg)
 	}
-	return fmt.Sprintf("%s %s %s", m.Header, stringStructuredData, m.Msg)
+
+	return text.String()
 }
 
 // nolint:golint
 func StructuredDataString(sd rfc5424.StructuredData) string {
-	if len(sd) == 0 {
-		return "-"
-	}
+	text := strings.Builder{}
+	writeStructuredData(&text, sd)
 
-	elems := make([]string, len(sd))
-	for i, elem := range sd {
-		elems[i] = StructuredDataElementString(elem)
-	}
-	return strings.Join(elems, "")
+	return text.String()
 }
 
 // nolint:golint
 func StructuredDataElementString(sde rfc5424.StructuredDataElement) string {
-	params := sde.Params()
-	if len(params) == 0 {
-		return fmt.Sprintf("[%s]", sde.ID())
-	}
+	text := strings.Builder{}
+	writeStructuredDataElement(&text, sde)
 
-	paramStrs := make([]string, len(params))
-	for i, param := range params {
-		paramStrs[i] = StructuredDataParamSting(param)
-	}
-	return fmt.Sprintf("[%s %s]", sde.ID(), strings.Join(paramStrs, " "))
+	return text.String()
 }
 
 // nolint:golint
 func StructuredDataParamSting(sdp rfc5424.StructuredDataParam) string {
-	return fmt.Sprintf(`%s="%s"`, sdp.Name, sdp.Value)
+	return string(sdp.Name) + `="` + sdp.Value.String() + `"`
+}
+
+// writeStructuredData writes the STRUCTURED-DATA directly to the builder ("-", if empty)
+func writeStructuredData(text *strings.Builder, sd rfc5424.StructuredData) {
+	if len(sd) == 0 {
+		text.WriteByte('-')
+		return
+	}
+
+	for _, elem := range sd {
+		writeStructuredDataElement(text, elem)
+	}
+}
+
+// writeStructuredDataElement writes the SD-ELEMENT directly to the builder
+func writeStructuredDataElement(text *strings.Builder, sde rfc5424.StructuredDataElement) {
+	text.WriteByte('[')
+	text.WriteString(string(sde.ID()))
+	for _, param := range sde.Params() {
+		text.WriteByte(' ')
+		text.WriteString(string(param.Name))
+		text.WriteString(`="`)
+		writeStructuredDataParamValue(text, string(param.Value))
+		text.WriteByte('"')
+	}
+	text.WriteByte(']')
+}
+
+// writeStructuredDataParamValue writes the escaped PARAM-VALUE, same as StructuredDataParamValue.String
+func writeStructuredDataParamValue(text *strings.Builder, value string) {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\', '"', ']':
+			text.WriteByte('\\')
+		}
+		text.WriteByte(value[i])
+	}
 }
 
 // nolint:golint
@@ -228,6 +359,10 @@ func (de *JSONDataElement) Validate() error { return nil }
 
 // nolint:golint
 func FixStructuredDataName(name string) string {
+	if strings.IndexFunc(name, isInvalidStructuredDataNameRune) < 0 {
+		return name
+	}
+
 	str := strings.Builder{}
 
 	for _, b := range []byte(name) {
@@ -241,6 +376,11 @@ func FixStructuredDataName(name string) string {
 	return str.String()
 }
 
+// isInvalidStructuredDataNameRune returns true, if the character is not allowed in SD-NAME
+func isInvalidStructuredDataNameRune(r rune) bool {
+	return r < '!' || r > '~' || r == '=' || r == ' ' || r == ']' || r == '"'
+}
+
 // prefixFieldClashes is a copy of Logrus feature
 func prefixFieldClashes(data log.Fields, key string) {
 	if v, ok := data[key]; ok {
@@ -3,11 +3,13 @@ package errfmt
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"emperror.dev/errors"
 	"github.com/juju/rfc/rfc5424"
 	log "github.com/sirupsen/logrus"
 )
@@ -75,3 +77,56 @@ func TestSyslog_WithErrorDetailsCallStack_CallStackInFields(t *testing.T) {
 	// nolint:lll
 	assert.Equal(t, `<27>1 `+tsRFC3339+` fqdn.host.com application PID DETAILS_CALLS_MSG [details level="error" func="`+funcName+`" error="MESSAGE 4: MESSAGE:2: MESSAGE%0: strconv.Atoi: parsing \\\"NO_NUMBER\\\": invalid syntax" file="formatter_syslog_test.go:0" K0_1="V0_1" K0_2="V0_2" K1_1="V1_1" K1_2="V1_2" K3_2="V3 space" K3_5="V3\\\"doublequote" K3%6="V3%percent" K3:3="V3:column" K3;3="V3;semicolumn" K3_1="V3=equal" K5_bool="true" K5_int="12" K5_map="{\"1\":\"ONE\",\"2\":\"TWO\"}" K5_struct="{\"Text\":\"text\",\"Integer\":42,\"Bool\":true}"][calls callstack="[\"errfmt.newWithDetails() errfmt.go:0\",\"errfmt.GenerateDeepErrors() errfmt.go:0\",\"`+funcName+`() formatter_syslog_test.go:0\"\]"] USER MSG`, replaceCallLine(loggerMock.outBuf.String()))
 }
+
+func TestSyslog_GetMsgID(t *testing.T) {
+	errSentinel := errors.Sentinel("sentinel error")
+	loggerMock := newSyslogLoggerMock(FlagNone, 2)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedSyslogFormatter)
+	assert.True(t, ok, "AdvancedSyslogFormatter")
+	formatter.MsgIDDetailKey = "code"
+	formatter.RegisterMsgIDError(errSentinel, "SENTINEL")
+
+	entry := loggerMock.WithError(errors.WithDetails(errors.WithMessage(errSentinel, "MESSAGE"), "code", "E42"))
+	assert.Equal(t, rfc5424.MsgID("SENTINEL"), formatter.GetMsgID(entry, MsgIDDetails))
+
+	entry = loggerMock.WithError(errors.NewWithDetails("MESSAGE", "code", "E 42"))
+	assert.Equal(t, rfc5424.MsgID("E_42"), formatter.GetMsgID(entry, MsgIDDetails))
+
+	entry = loggerMock.WithField("code", 42)
+	assert.Equal(t, rfc5424.MsgID("42"), formatter.GetMsgID(entry, MsgIDDetails))
+
+	entry = loggerMock.WithError(errors.New("MESSAGE"))
+	assert.Equal(t, rfc5424.MsgID(MsgIDDetails), formatter.GetMsgID(entry, MsgIDDetails))
+
+	formatter.MsgID = "FIXED"
+	assert.Equal(t, rfc5424.MsgID("FIXED"), formatter.GetMsgID(entry, MsgIDDetails))
+
+	formatter.MsgIDFunc = func(entry *log.Entry) string {
+		return "FROM_" + entry.Message
+	}
+	entry.Message = "CALLBACK"
+	assert.Equal(t, rfc5424.MsgID("FROM_CALLBACK"), formatter.GetMsgID(entry, MsgIDDetails))
+}
+
+func TestSyslog_MsgIDFunc(t *testing.T) {
+	loggerMock := newSyslogLoggerMock(FlagNone, 2)
+	formatter, ok := loggerMock.Logger.Formatter.(*AdvancedSyslogFormatter)
+	assert.True(t, ok, "AdvancedSyslogFormatter")
+	formatter.MsgIDFunc = func(entry *log.Entry) string {
+		return entry.Level.String()
+	}
+	ts := time.Now()
+	tsRFC3339 := ts.Format(time.RFC3339Nano)
+
+	loggerMock.WithTime(ts).Warn("USER MSG")
+
+	assert.True(t, strings.HasPrefix(loggerMock.outBuf.String(),
+		`<28>1 `+tsRFC3339+` fqdn.host.com application PID warning [details `),
+		loggerMock.outBuf.String())
+}
+
+func TestFixMsgID(t *testing.T) {
+	assert.Equal(t, "ABC_D", FixMsgID("ABC D"))
+	assert.Equal(t, "_", FixMsgID("á")[:1])
+	assert.Equal(t, strings.Repeat("X", MsgIDMaxLength), FixMsgID(strings.Repeat("X", MsgIDMaxLength+10)))
+}
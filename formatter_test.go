@@ -0,0 +1,100 @@
+package errfmt
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestFilterCallStackFrames(t *testing.T) {
+	AddSkipPackageFromStackTrace("example.com/app")
+	callStackFrames := []CallStackFrame{
+		{Function: "example.com/app/db.Query", Path: "/src/app/db/db.go", Line: 10},
+		{Function: "example.com/app/api.(*Server).getUser", Path: "/src/app/api/user.go", Line: 20},
+		{Function: "net/http.HandlerFunc.ServeHTTP", Path: "/go/src/net/http/server.go", Line: 2007},
+		{Function: "github.com/gorilla/mux.(*Router).ServeHTTP", Path: "/mod/mux/mux.go", Line: 210},
+		{Function: "net/http.serverHandler.ServeHTTP", Path: "/go/src/net/http/server.go", Line: 2802},
+		{Function: "net/http.(*conn).serve", Path: "/go/src/net/http/server.go", Line: 1890},
+		{Function: "runtime.goexit", Path: "/go/src/runtime/asm_amd64.s", Line: 1357},
+	}
+
+	f := AdvancedFormatter{
+		CallStackDropPackages:     []string{"runtime"},
+		CallStackCollapsePackages: []string{"net/http", "github.com/gorilla"},
+	}
+	assert.Equal(t, []string{
+		"db.Query() db.go:10",
+		"api.(*Server).getUser() user.go:20",
+		"... 4 frames elided",
+	}, buildCallStackLines(f.FilterCallStackFrames(callStackFrames), DefaultPackageTrimmer()))
+
+	f = AdvancedFormatter{
+		Flags:                 FlagCallStackAppOnly,
+		CallStackDropPackages: []string{"example.com/app/db"},
+	}
+	assert.Equal(t, []string{
+		"api.(*Server).getUser() user.go:20",
+	}, buildCallStackLines(f.FilterCallStackFrames(callStackFrames), DefaultPackageTrimmer()))
+
+	f = AdvancedFormatter{Flags: FlagCallStackAppOnly}
+	assert.Len(t, f.FilterCallStackFrames(callStackFrames[2:]), 5, "no application frame")
+}
+
+func TestCallStackSkipFirst(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newTextLoggerMock(FlagCallStackInFields, 2)
+	f := GetAdvancedFormatter(loggerMock.Formatter)
+	f.CallStackSkipFirst = 1
+
+	assert.Equal(t, "errfmt.GenerateDeepErrors() errfmt.go:0\n"+funcName+"() formatter_test.go:0",
+		replaceCallLine(strings.Join(f.GetCallStack(loggerMock.WithError(GenerateDeepErrors())), "\n")))
+
+	f.CallStackSkipFirst = 3
+	assert.Empty(t, f.GetCallStack(loggerMock.WithError(GenerateDeepErrors())))
+}
+
+func TestFormat_NonMutating_Concurrent(t *testing.T) {
+	flags := FlagExtractDetails | FlagCallStackInFields | FlagCallStackOnConsole | FlagErrorFingerprint
+	nestedJSON := NewAdvancedJSONFormatter(flags|FlagNestedJSON, 2)
+	nestedJSON.SourceURLTemplate = testSourceURLTemplate
+	formatters := map[string]log.Formatter{
+		"text":       NewAdvancedTextFormatter(flags|FlagPrintStructFieldNames, 2),
+		"json":       NewAdvancedJSONFormatter(flags, 2),
+		"nestedJSON": nestedJSON,
+	}
+
+	logger := log.New()
+	entry := logger.WithError(GenerateDeepErrors()).WithField("STR", "str").WithTime(time.Now())
+	entry.Level = log.ErrorLevel
+	entry.Message = "USER MSG"
+	origData := log.Fields{}
+	for k, v := range entry.Data {
+		origData[k] = v
+	}
+
+	for name, formatter := range formatters {
+		first, err := formatter.Format(entry)
+		assert.Nil(t, err, name)
+		assert.Equal(t, origData, entry.Data, name)
+
+		wg := sync.WaitGroup{}
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(name string, formatter log.Formatter) {
+				defer wg.Done()
+				for i := 0; i < 10; i++ {
+					textPart, err := formatter.Format(entry)
+					assert.Nil(t, err, name)
+					assert.Equal(t, string(first), string(textPart), name)
+				}
+			}(name, formatter)
+		}
+		wg.Wait()
+		assert.Equal(t, origData, entry.Data, name)
+	}
+}
@@ -11,7 +11,7 @@ NewTextLogger builds a customized Logrus JSON logger+formatter
 	Features:
 	* CallStackSkipLast
 	* CallStackNewLines and CallStackInFields
-	* ModuleCallerPrettyfier
+	* AdvancedFormatter.TrimCaller (see PackageTrimmer)
 	* PrintStructFieldNames
 */
 func NewTextLogger(level log.Level, flags int, callStackSkipLast int,
@@ -28,7 +28,7 @@ func NewTextLogger(level log.Level, flags int, callStackSkipLast int,
 /*
 AdvancedTextFormatter is a customized Logrus Text formatter
 	Features:
-	* ModuleCallerPrettyfier
+	* AdvancedFormatter.TrimCaller (see PackageTrimmer)
 	* PrintStructFieldNames
 	* AdvancedFieldOrder
 */
@@ -39,9 +39,8 @@ type AdvancedTextFormatter struct {
 
 // NewAdvancedTextFormatter makes a new AdvancedTextFormatter
 func NewAdvancedTextFormatter(flags int, callStackSkipLast int) *AdvancedTextFormatter {
-	return &AdvancedTextFormatter{
+	formatter := &AdvancedTextFormatter{
 		TextFormatter: log.TextFormatter{
-			CallerPrettyfier: ModuleCallerPrettyfier,
 			SortingFunc:      SortingFuncDecorator(AdvancedFieldOrder()),
 			DisableColors:    true,
 			QuoteEmptyFields: true,
@@ -51,23 +50,31 @@ func NewAdvancedTextFormatter(flags int, callStackSkipLast int) *AdvancedTextFor
 			CallStackSkipLast: callStackSkipLast,
 		},
 	}
+	formatter.TextFormatter.CallerPrettyfier = formatter.TrimCaller
+
+	return formatter
 }
 
 // Format implements logrus.Formatter interface
 // nolint:gocyclo,funlen
 func (f *AdvancedTextFormatter) Format(entry *log.Entry) ([]byte, error) {
-	entry.Data = f.MergeDetailsToFields(entry)
+	entry = f.CheckStack(entry)
+	data := f.MergeDetailsToFields(entry)
 	callStackLines := f.GetCallStack(entry)
-	if (f.Flags & FlagCallStackInFields) > 0 {
-		entry.Data[KeyCallStack] = callStackLines
+	consoleCallStackLines := callStackLines
+	if (f.Flags & FlagCallStackSourceContext) > 0 {
+		consoleCallStackLines = f.GetCallStackWithSource(entry)
+	}
+	if (f.Flags&FlagCallStackInFields) > 0 && !f.IsStackSuppressed(entry) {
+		data[KeyCallStack] = callStackLines
 	}
 
-	f.RenderFieldValues(entry.Data)
+	f.RenderFieldValues(data)
 
-	textPart, err := f.TextFormatter.Format(entry)
+	textPart, err := f.TextFormatter.Format(CopyEntry(entry, data))
 
 	if (f.Flags & FlagCallStackOnConsole) > 0 {
-		textPart = f.AppendCallStack(textPart, callStackLines)
+		textPart = f.AppendCallStack(textPart, consoleCallStackLines)
 	}
 
 	return textPart, err
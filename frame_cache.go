@@ -0,0 +1,186 @@
+package errfmt
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// FrameCacheSize is the max. number of cached frames of DefaultFrameCache (per kind: resolved and trimmed)
+	FrameCacheSize = 4096
+)
+
+// defaultFrameCache is the frame cache of the call stack resolution and trimming
+var defaultFrameCache *FrameCache // nolint:gochecknoglobals
+
+// init allocates defaultFrameCache on the heap, because a statically allocated
+// package-level composite literal isn't 64-bit aligned on 32-bit platforms
+func init() { // nolint:gochecknoinits
+	defaultFrameCache = NewFrameCache(FrameCacheSize)
+}
+
+/*
+FrameCache is a bounded, concurrency-safe cache of frames, keyed by program counter
+	Resolved frames (function, file path, line) are cached by PC, trimmed frames
+	(see PackageTrimmer) are cached by PC and trimmer. The cache is cleared, if it's full.
+	See DefaultFrameCache and Stats.
+*/
+type FrameCache struct {
+	hits      uint64 // atomic, first for 64-bit alignment
+	misses    uint64 // atomic
+	evictions uint64 // atomic
+
+	size    int
+	mu      sync.RWMutex
+	frames  map[uintptr]CallStackFrame
+	trimmed map[trimmedFrameKey]trimmedFrame
+}
+
+// FrameCacheStats are the diagnostic counters of FrameCache
+type FrameCacheStats struct {
+	// Hits is the number of lookups found in the cache
+	Hits uint64
+	// Misses is the number of lookups not found in the cache (resolved or trimmed)
+	Misses uint64
+	// Evictions is the number of cache clears (full cache)
+	Evictions uint64
+	// Frames is the number of cached resolved frames
+	Frames int
+	// TrimmedFrames is the number of cached trimmed frames
+	TrimmedFrames int
+}
+
+// trimmedFrameKey is the key of a trimmed frame: PC and trimmer
+type trimmedFrameKey struct {
+	pc      uintptr
+	trimmer *PackageTrimmer
+}
+
+// trimmedFrame is a trimmed frame, valid for the source frame and trimmer state
+type trimmedFrame struct {
+	function   string
+	path       string
+	line       int
+	generation uint64
+	filePaths  bool
+
+	trimmedFunction string
+	fileLine        string // trimmed file path and line
+	frameString     string // see PackageTrimmer.FrameString
+}
+
+// NewFrameCache makes a new FrameCache with max. size
+func NewFrameCache(size int) *FrameCache {
+	return &FrameCache{
+		size:    size,
+		frames:  map[uintptr]CallStackFrame{},
+		trimmed: map[trimmedFrameKey]trimmedFrame{},
+	}
+}
+
+// DefaultFrameCache returns the frame cache of the formatters
+func DefaultFrameCache() *FrameCache {
+	return defaultFrameCache
+}
+
+/*
+Resolve resolves the function name, file path, line and function entry of the return address
+	Same as the "%+s" and "%d" formats of errors.Frame ("unknown" function, if not resolvable),
+	without formatting.
+*/
+func (c *FrameCache) Resolve(pc uintptr) CallStackFrame {
+	c.mu.RLock()
+	frame, ok := c.frames[pc]
+	c.mu.RUnlock()
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+		return frame
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	frame = CallStackFrame{Function: "unknown", PC: pc}
+	if fn := runtime.FuncForPC(pc - 1); fn != nil {
+		frame.Function = fn.Name()
+		frame.Path, frame.Line = fn.FileLine(pc - 1)
+		frame.Entry = fn.Entry()
+	}
+
+	c.mu.Lock()
+	if len(c.frames) >= c.size {
+		c.frames = map[uintptr]CallStackFrame{}
+		atomic.AddUint64(&c.evictions, 1)
+	}
+	c.frames[pc] = frame
+	c.mu.Unlock()
+
+	return frame
+}
+
+// Stats returns the diagnostic counters
+func (c *FrameCache) Stats() FrameCacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return FrameCacheStats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Evictions:     atomic.LoadUint64(&c.evictions),
+		Frames:        len(c.frames),
+		TrimmedFrames: len(c.trimmed),
+	}
+}
+
+// Reset clears the cache and the counters
+func (c *FrameCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.frames = map[uintptr]CallStackFrame{}
+	c.trimmed = map[trimmedFrameKey]trimmedFrame{}
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.evictions, 0)
+}
+
+/*
+trim returns the trimmed frame by the trimmer
+	Frames without PC (for example built by hand) are not cached. The cached frame is
+	valid, while the frame and the trimmer configuration (prefixes, FilePaths) are unchanged.
+*/
+func (c *FrameCache) trim(pc uintptr, function string, path string, line int, trimmer *PackageTrimmer) trimmedFrame {
+	key := trimmedFrameKey{pc: pc, trimmer: trimmer}
+	generation := trimmer.generation()
+	if pc != 0 {
+		c.mu.RLock()
+		cached, ok := c.trimmed[key]
+		c.mu.RUnlock()
+		if ok && cached.function == function && cached.path == path && cached.line == line &&
+			cached.generation == generation && cached.filePaths == trimmer.FilePaths {
+			atomic.AddUint64(&c.hits, 1)
+			return cached
+		}
+		atomic.AddUint64(&c.misses, 1)
+	}
+
+	trimmed := trimmedFrame{
+		function: function, path: path, line: line,
+		generation: generation, filePaths: trimmer.FilePaths,
+		trimmedFunction: trimmer.TrimFunctionName(function),
+	}
+	trimmed.fileLine = trimmer.TrimFilePath(function, path) + ":" + strconv.Itoa(line)
+	trimmed.frameString = trimmed.trimmedFunction + "() " + trimmed.fileLine
+
+	if pc != 0 {
+		c.mu.Lock()
+		if len(c.trimmed) >= c.size {
+			c.trimmed = map[trimmedFrameKey]trimmedFrame{}
+			atomic.AddUint64(&c.evictions, 1)
+		}
+		c.trimmed[key] = trimmed
+		c.mu.Unlock()
+	}
+
+	return trimmed
+}
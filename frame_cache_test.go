@@ -0,0 +1,107 @@
+package errfmt
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameCache_Resolve(t *testing.T) {
+	var stackTracer StackTracer
+	assert.True(t, errors.As(GenerateDeepErrors(), &stackTracer))
+
+	cache := NewFrameCache(FrameCacheSize)
+	for _, frame := range stackTracer.StackTrace() {
+		resolved := cache.Resolve(uintptr(frame))
+		assert.Equal(t, fmt.Sprintf("%+s", frame), resolved.Function+"\n\t"+resolved.Path)
+		assert.Equal(t, fmt.Sprintf("%d", frame), strconv.Itoa(resolved.Line))
+		assert.Equal(t, resolved, cache.Resolve(uintptr(frame)))
+	}
+	assert.Equal(t, CallStackFrame{Function: "unknown"}, cache.Resolve(0))
+
+	frameCount := len(stackTracer.StackTrace())
+	assert.Equal(t, FrameCacheStats{
+		Hits: uint64(frameCount), Misses: uint64(frameCount + 1), Frames: frameCount + 1,
+	}, cache.Stats())
+
+	cache.Reset()
+	assert.Equal(t, FrameCacheStats{}, cache.Stats())
+}
+
+func TestFrameCache_Bounded(t *testing.T) {
+	var stackTracer StackTracer
+	assert.True(t, errors.As(GenerateDeepErrors(), &stackTracer))
+
+	cache := NewFrameCache(2)
+	for _, frame := range stackTracer.StackTrace()[:3] {
+		cache.Resolve(uintptr(frame))
+	}
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 1, stats.Frames)
+}
+
+func TestFrameCache_Trim(t *testing.T) {
+	pc, file, line, _ := runtime.Caller(0)
+	function := runtime.FuncForPC(pc).Name()
+	trimmer := NewPackageTrimmer()
+
+	cache := NewFrameCache(FrameCacheSize)
+	assert.Equal(t, function, cache.trim(pc, function, file, line, trimmer).trimmedFunction)
+	assert.Equal(t, function+"() frame_cache_test.go:"+strconv.Itoa(line),
+		cache.trim(pc, function, file, line, trimmer).frameString)
+	assert.Equal(t, FrameCacheStats{Hits: 1, Misses: 1, TrimmedFrames: 1}, cache.Stats())
+
+	trimmer.AddPrefix(MainModulePath())
+	assert.Equal(t, "TestFrameCache_Trim", cache.trim(pc, function, file, line, trimmer).trimmedFunction)
+	assert.Equal(t, uint64(2), cache.Stats().Misses)
+
+	assert.Equal(t, "other", cache.trim(pc, "other", file, line, trimmer).trimmedFunction)
+	assert.Equal(t, "unknown.F() file.go:1", cache.trim(0, "unknown.F", "/src/file.go", 1, trimmer).frameString)
+	assert.Equal(t, FrameCacheStats{Hits: 1, Misses: 3, TrimmedFrames: 1}, cache.Stats())
+}
+
+func TestFrameCache_Concurrent(t *testing.T) {
+	loggerMock := newJSONLoggerMock(FlagCallStackInFields, 2)
+	DefaultFrameCache().Reset()
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				loggerMock.WithError(GenerateDeepErrors()).Error("USER MSG")
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := DefaultFrameCache().Stats()
+	assert.Greater(t, stats.Hits, stats.Misses)
+	assert.Greater(t, stats.Frames, 0)
+	assert.Greater(t, stats.TrimmedFrames, 0)
+}
+
+// TestAtomicAlignment checks the 64-bit alignment of the atomic counters (run also by GOARCH=386 go test)
+func TestAtomicAlignment(t *testing.T) {
+	asyncWriter := NewAsyncWriter(&bytes.Buffer{}, 1, AsyncDropNewest)
+	defer asyncWriter.Close() // nolint:errcheck
+
+	for name, counter := range map[string]unsafe.Pointer{
+		"DefaultFrameCache().hits":                  unsafe.Pointer(&DefaultFrameCache().hits),
+		"NewFrameCache().hits":                      unsafe.Pointer(&NewFrameCache(1).hits),
+		"DefaultPackageTrimmer().generationCounter": unsafe.Pointer(&DefaultPackageTrimmer().generationCounter),
+		"NewPackageTrimmer().generationCounter":     unsafe.Pointer(&NewPackageTrimmer().generationCounter),
+		"NewAsyncWriter().written":                  unsafe.Pointer(&asyncWriter.written),
+	} {
+		assert.Zero(t, uintptr(counter)%8, name)
+	}
+}
@@ -0,0 +1,300 @@
+package errfmt
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// FluentdBatchSize is the default number of entries in one Forward message
+	FluentdBatchSize = 1
+	// FluentdQueueSize is the default max. number of pending entries
+	FluentdQueueSize = 8192
+	// FluentdMaxRetries is the default number of retries after a failed send
+	FluentdMaxRetries = 3
+	// FluentdRetryWait is the default initial wait before retry (doubled after each retry)
+	FluentdRetryWait = 100 * time.Millisecond
+	// FluentdTimeout is the default dial, write and ack timeout
+	FluentdTimeout = 3 * time.Second
+)
+
+// ErrFluentdHookClosed is returned by FluentdHook.Fire and Flush after Close
+var ErrFluentdHookClosed = errors.NewPlain("Fluentd hook is closed") // nolint:gochecknoglobals
+
+/*
+FluentdHook is a logrus.Hook, sending entries to Fluentd by the Forward protocol (MessagePack)
+	Records are built by PrepareFields, so errors, details (see FlagExtractDetails),
+	structs, maps and call stack (see FlagCallStackInFields) are converted correctly.
+	Fire only queues the record (max. QueueSize, further entries are dropped),
+	entries are sent in the background in batches of BatchSize (and by FlushInterval, if set),
+	failed sends are retried, acknowledgement is requested, if RequireAck is set.
+	Send errors and dropped entries are returned by the next Flush or Close.
+*/
+type FluentdHook struct {
+	AdvancedFormatter
+	Address string
+	Tag     string
+	// LogLevels is the return value of Levels()
+	LogLevels     []log.Level
+	BatchSize     int
+	QueueSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	RetryWait     time.Duration
+	Timeout       time.Duration
+	RequireAck    bool
+
+	mu      sync.Mutex // guards entries, dropped and closed
+	entries []interface{}
+	dropped int
+	closed  bool
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	wakeup    chan struct{}
+	flushes   chan chan error
+	stop      chan struct{}
+	done      chan struct{}
+	closeErr  error
+
+	// owned by the sender goroutine
+	conn    net.Conn
+	reader  *bufio.Reader
+	sendErr error // last background error
+}
+
+// NewFluentdHook makes a new FluentdHook to the address (host:port)
+func NewFluentdHook(flags int, callStackSkipLast int, address string, tag string) *FluentdHook {
+	return &FluentdHook{
+		AdvancedFormatter: AdvancedFormatter{
+			Flags:             flags,
+			CallStackSkipLast: callStackSkipLast,
+		},
+		Address:    address,
+		Tag:        tag,
+		LogLevels:  log.AllLevels,
+		BatchSize:  FluentdBatchSize,
+		QueueSize:  FluentdQueueSize,
+		MaxRetries: FluentdMaxRetries,
+		RetryWait:  FluentdRetryWait,
+		Timeout:    FluentdTimeout,
+		wakeup:     make(chan struct{}, 1),
+		flushes:    make(chan chan error),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Levels implements logrus.Hook interface
+func (h *FluentdHook) Levels() []log.Level {
+	return h.LogLevels
+}
+
+// Fire implements logrus.Hook interface, the record is queued (never blocks on the network)
+func (h *FluentdHook) Fire(entry *log.Entry) error {
+	h.startOnce.Do(h.start)
+	event := []interface{}{msgpackEventTime(entry.Time), h.BuildRecord(entry)}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrFluentdHookClosed
+	}
+	if h.QueueSize > 0 && len(h.entries) >= h.QueueSize {
+		h.dropped++
+		h.mu.Unlock()
+		return nil
+	}
+	h.entries = append(h.entries, event)
+	full := len(h.entries) >= h.BatchSize
+	h.mu.Unlock()
+
+	if full {
+		select {
+		case h.wakeup <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// BuildRecord builds the Fluentd record of the entry
+func (h *FluentdHook) BuildRecord(entry *log.Entry) map[string]interface{} {
+	entry = h.CheckStack(entry)
+	data := h.PrepareFields(entry, h.GetClashingFields())
+	data[log.FieldKeyMsg] = entry.Message
+	if level, ok := data[log.FieldKeyLevel].(log.Level); ok {
+		data[log.FieldKeyLevel] = level.String()
+	}
+
+	return data
+}
+
+// Flush sends the pending entries and returns the errors since the previous Flush
+func (h *FluentdHook) Flush() error {
+	h.startOnce.Do(h.start)
+
+	result := make(chan error, 1)
+	select {
+	case h.flushes <- result:
+		return <-result
+	case <-h.done:
+		return ErrFluentdHookClosed
+	}
+}
+
+// Close flushes the pending entries, stops the sender and closes the connection (can be called more times)
+func (h *FluentdHook) Close() error {
+	h.closeOnce.Do(func() {
+		h.startOnce.Do(h.start)
+
+		h.mu.Lock()
+		h.closed = true
+		h.mu.Unlock()
+
+		close(h.stop)
+	})
+	<-h.done
+
+	return h.closeErr
+}
+
+// start starts the sender goroutine
+func (h *FluentdHook) start() {
+	go h.run()
+}
+
+// run sends the queued entries in the background, by full batch, FlushInterval, Flush and Close
+func (h *FluentdHook) run() {
+	defer close(h.done)
+
+	var tick <-chan time.Time
+	if h.FlushInterval > 0 {
+		ticker := time.NewTicker(h.FlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-h.wakeup:
+			h.keepError(h.flush())
+		case <-tick:
+			h.keepError(h.flush())
+		case result := <-h.flushes:
+			result <- h.takeErrors(h.flush())
+		case <-h.stop:
+			h.closeErr = h.takeErrors(h.flush())
+			h.disconnect()
+			return
+		}
+	}
+}
+
+// keepError keeps the last background error for the next Flush or Close
+func (h *FluentdHook) keepError(err error) {
+	if err != nil {
+		h.sendErr = err
+	}
+}
+
+// takeErrors returns the kept and the current errors, the kept error is cleared
+func (h *FluentdHook) takeErrors(err error) error {
+	err = errors.Combine(h.sendErr, err)
+	h.sendErr = nil
+
+	return err
+}
+
+// flush sends the pending entries with retries, called only by the sender goroutine
+func (h *FluentdHook) flush() error {
+	h.mu.Lock()
+	entries := h.entries
+	h.entries = nil
+	dropped := h.dropped
+	h.dropped = 0
+	h.mu.Unlock()
+
+	var queueErr error
+	if dropped > 0 {
+		queueErr = errors.NewWithDetails("Fluentd queue is full", "dropped", dropped)
+	}
+	if len(entries) == 0 {
+		return queueErr
+	}
+
+	var err error
+	wait := h.RetryWait
+	for attempt := 0; attempt <= h.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+		if err = h.send(entries); err == nil {
+			return queueErr
+		}
+		h.disconnect()
+	}
+
+	return errors.Combine(queueErr, errors.WithDetails(err, "dropped", len(entries)))
+}
+
+// send sends one Forward mode message: [tag, [[time, record], ...], option]
+func (h *FluentdHook) send(entries []interface{}) error {
+	if h.conn == nil {
+		conn, err := net.DialTimeout("tcp", h.Address, h.Timeout)
+		if err != nil {
+			return errors.WrapWithDetails(err, "cannot connect to Fluentd", "address", h.Address)
+		}
+		h.conn = conn
+		h.reader = bufio.NewReader(conn)
+	}
+
+	option := map[string]interface{}{"size": len(entries)}
+	chunk := ""
+	if h.RequireAck {
+		chunkID := make([]byte, 16)
+		if _, err := rand.Read(chunkID); err != nil {
+			return errors.WrapIf(err, "cannot generate chunk ID")
+		}
+		chunk = base64.StdEncoding.EncodeToString(chunkID)
+		option["chunk"] = chunk
+	}
+
+	message := msgpackEncode(nil, []interface{}{h.Tag, entries, option})
+	if err := h.conn.SetDeadline(time.Now().Add(h.Timeout)); err != nil {
+		return errors.WrapIf(err, "cannot set Fluentd deadline")
+	}
+	if _, err := h.conn.Write(message); err != nil {
+		return errors.WrapWithDetails(err, "cannot send to Fluentd", "address", h.Address)
+	}
+
+	if h.RequireAck {
+		response, err := msgpackDecode(h.reader)
+		if err != nil {
+			return errors.WrapWithDetails(err, "cannot read Fluentd ack", "address", h.Address)
+		}
+		if ack, ok := response.(map[string]interface{}); !ok || ack["ack"] != chunk {
+			return errors.NewWithDetails("invalid Fluentd ack", "chunk", chunk, "response", response)
+		}
+	}
+
+	return nil
+}
+
+// disconnect closes the connection, called only by the sender goroutine
+func (h *FluentdHook) disconnect() {
+	if h.conn != nil {
+		h.conn.Close() // nolint:errcheck,gosec
+		h.conn = nil
+		h.reader = nil
+	}
+}
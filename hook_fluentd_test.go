@@ -0,0 +1,218 @@
+package errfmt
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fluentdServerMock is an in-process Fluentd Forward server stand-in
+type fluentdServerMock struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []interface{}
+	// dropFirst closes the first connection without reading (for testing retry)
+	dropFirst bool
+}
+
+func newFluentdServerMock(t *testing.T, dropFirst bool) *fluentdServerMock {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	server := &fluentdServerMock{listener: listener, dropFirst: dropFirst}
+	go server.serve()
+
+	return server
+}
+
+func (s *fluentdServerMock) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		drop := s.dropFirst
+		s.dropFirst = false
+		s.mu.Unlock()
+		if drop {
+			conn.Close() // nolint:errcheck,gosec
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fluentdServerMock) handle(conn net.Conn) {
+	defer conn.Close() // nolint:errcheck
+	reader := bufio.NewReader(conn)
+	for {
+		message, err := msgpackDecode(reader)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.messages = append(s.messages, message)
+		s.mu.Unlock()
+
+		if items, ok := message.([]interface{}); ok && len(items) == 3 {
+			if option, ok := items[2].(map[string]interface{}); ok && option["chunk"] != nil {
+				conn.Write(msgpackEncode(nil, map[string]interface{}{"ack": option["chunk"]})) // nolint:errcheck,gosec
+			}
+		}
+	}
+}
+
+func (s *fluentdServerMock) getMessages() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]interface{}{}, s.messages...)
+}
+
+func TestFluentdHook_Batch_Ack(t *testing.T) {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+	server := newFluentdServerMock(t, false)
+	defer server.listener.Close() // nolint:errcheck
+
+	hook := NewFluentdHook(FlagExtractDetails|FlagCallStackInFields, 2, server.listener.Addr().String(), "app.log")
+	hook.BatchSize = 2
+	hook.RequireAck = true
+
+	logger := log.New()
+	ts := time.Unix(1571170345, 123456789)
+	err := GenerateDeepErrors()
+	assert.Nil(t, hook.Fire(logger.WithError(err).WithTime(ts).WithFields(log.Fields{"K": "V"})))
+	assert.Empty(t, server.getMessages(), "batched")
+	assert.Nil(t, hook.Fire(logger.WithTime(ts).WithField("K6_map", map[int]string{1: "ONE"})))
+	assert.Nil(t, hook.Close())
+
+	messages := server.getMessages()
+	assert.Len(t, messages, 1)
+	message, ok := messages[0].([]interface{})
+	assert.True(t, ok, "message")
+	assert.Equal(t, "app.log", message[0])
+	entries, ok := message[1].([]interface{})
+	assert.True(t, ok, "entries")
+	assert.Len(t, entries, 2)
+	option, ok := message[2].(map[string]interface{})
+	assert.True(t, ok, "option")
+	assert.Equal(t, int64(2), option["size"])
+	assert.NotEmpty(t, option["chunk"])
+
+	first, ok := entries[0].([]interface{})
+	assert.True(t, ok, "entry")
+	assert.True(t, ts.Equal(first[0].(time.Time)))
+	record, ok := first[1].(map[string]interface{})
+	assert.True(t, ok, "record")
+	assert.Equal(t, err.Error(), record[log.ErrorKey])
+	assert.Equal(t, "V", record["K"])
+	assert.Equal(t, "V0_1", record["K0_1"])
+	assert.Equal(t, true, record["K5_bool"])
+	assert.Equal(t, int64(12), record["K5_int"])
+	assert.Equal(t, map[string]interface{}{"1": "ONE", "2": "TWO"}, record["K5_map"])
+	assert.Equal(t, map[string]interface{}{"Text": "text", "Integer": int64(42), "Bool": true}, record["K5_struct"])
+	assert.Equal(t, []interface{}{
+		"errfmt.newWithDetails() errfmt.go:0",
+		"errfmt.GenerateDeepErrors() errfmt.go:0",
+		FunctionNameShort() + "() hook_fluentd_test.go:0",
+	}, replaceCallLines(record[KeyCallStack]))
+
+	second, ok := entries[1].([]interface{})
+	assert.True(t, ok, "entry")
+	record, ok = second[1].(map[string]interface{})
+	assert.True(t, ok, "record")
+	assert.Equal(t, map[string]interface{}{"1": "ONE"}, record["K6_map"])
+}
+
+func TestFluentdHook_Retry(t *testing.T) {
+	server := newFluentdServerMock(t, true)
+	defer server.listener.Close() // nolint:errcheck
+
+	hook := NewFluentdHook(FlagNone, 0, server.listener.Addr().String(), "app.log")
+	hook.RequireAck = true
+	hook.RetryWait = time.Millisecond
+
+	assert.Nil(t, hook.Fire(log.NewEntry(log.New())))
+	assert.Nil(t, hook.Close())
+	assert.Len(t, server.getMessages(), 1)
+}
+
+func TestFluentdHook_FlushInterval(t *testing.T) {
+	server := newFluentdServerMock(t, false)
+	defer server.listener.Close() // nolint:errcheck
+
+	hook := NewFluentdHook(FlagNone, 0, server.listener.Addr().String(), "app.log")
+	hook.BatchSize = 100
+	hook.FlushInterval = 10 * time.Millisecond
+	defer hook.Close() // nolint:errcheck
+
+	assert.Nil(t, hook.Fire(log.NewEntry(log.New())))
+	assert.Eventually(t, func() bool { return len(server.getMessages()) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestFluentdHook_NoServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	address := listener.Addr().String()
+	listener.Close() // nolint:errcheck,gosec
+
+	hook := NewFluentdHook(FlagNone, 0, address, "app.log")
+	hook.RetryWait = time.Millisecond
+
+	assert.Nil(t, hook.Fire(log.NewEntry(log.New())))
+	assert.NotNil(t, hook.Flush())
+	assert.Nil(t, hook.Flush(), "dropped entries are not flushed again")
+	assert.Nil(t, hook.Close())
+	assert.Nil(t, hook.Close())
+	assert.Equal(t, ErrFluentdHookClosed, hook.Fire(log.NewEntry(log.New())))
+}
+
+func TestFluentdHook_NonBlocking(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	address := listener.Addr().String()
+	listener.Close() // nolint:errcheck,gosec
+
+	hook := NewFluentdHook(FlagNone, 0, address, "app.log")
+	hook.QueueSize = 10
+	hook.RetryWait = 100 * time.Millisecond
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.AddHook(hook)
+
+	started := time.Now()
+	for i := 0; i < 20; i++ {
+		logger.Info("MESSAGE")
+	}
+	assert.Less(t, int64(time.Since(started)), int64(100*time.Millisecond), "logging waits for Fluentd")
+
+	wg := sync.WaitGroup{}
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NotNil(t, hook.Close())
+		}()
+	}
+	wg.Wait()
+}
+
+func replaceCallLines(lines interface{}) []interface{} {
+	replaced := []interface{}{}
+	if items, ok := lines.([]interface{}); ok {
+		for _, item := range items {
+			if line, ok := item.(string); ok {
+				replaced = append(replaced, replaceCallLine(line))
+			}
+		}
+	}
+
+	return replaced
+}
@@ -0,0 +1,189 @@
+package errfmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/juju/rfc/rfc5424"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// JournaldSocket is the native journal socket of systemd-journald
+	JournaldSocket = "/run/systemd/journal/socket"
+	// JournaldFieldPrefix is the prefix of journal fields built from logrus.Fields and error details
+	JournaldFieldPrefix = "ERRFMT_"
+	// JournaldFieldMaxLength is the max. length of journal field names
+	JournaldFieldMaxLength = 64
+)
+
+/*
+JournaldHook is a logrus.Hook, sending entries to systemd-journald by the native protocol
+	Field mapping:
+	* MESSAGE: entry.Message
+	* PRIORITY: LevelToSeverity
+	* CODE_FILE, CODE_LINE, CODE_FUNC: entry.Caller
+	* SYSLOG_IDENTIFIER: Identifier, if not empty
+	* ERRFMT_*: logrus.Fields and error details (see FlagExtractDetails)
+	* ERRFMT_CALLSTACK: multi-line call stack (see FlagCallStackInFields)
+*/
+type JournaldHook struct {
+	AdvancedFormatter
+	LevelToSeverity map[log.Level]rfc5424.Severity
+	Identifier      string
+	// LogLevels is the return value of Levels()
+	LogLevels []log.Level
+
+	socketAddr *net.UnixAddr
+	conn       *net.UnixConn
+	mu         sync.Mutex
+}
+
+// NewJournaldHook makes a new JournaldHook, socketPath is JournaldSocket, if empty
+func NewJournaldHook(flags int, callStackSkipLast int, socketPath string, identifier string,
+) (*JournaldHook, error) {
+	if socketPath == "" {
+		socketPath = JournaldSocket
+	}
+
+	autobind, err := net.ResolveUnixAddr("unixgram", "")
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "cannot resolve local address", "socket", socketPath)
+	}
+	conn, err := net.ListenUnixgram("unixgram", autobind)
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "cannot open journal connection", "socket", socketPath)
+	}
+
+	return &JournaldHook{
+		AdvancedFormatter: AdvancedFormatter{
+			Flags:             flags,
+			CallStackSkipLast: callStackSkipLast,
+		},
+		LevelToSeverity: DefaultLevelToSeverity(),
+		Identifier:      identifier,
+		LogLevels:       log.AllLevels,
+		socketAddr:      &net.UnixAddr{Name: socketPath, Net: "unixgram"},
+		conn:            conn,
+	}, nil
+}
+
+// Levels implements logrus.Hook interface
+func (h *JournaldHook) Levels() []log.Level {
+	return h.LogLevels
+}
+
+// Fire implements logrus.Hook interface
+func (h *JournaldHook) Fire(entry *log.Entry) error {
+	payload, err := h.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, _, err := h.conn.WriteMsgUnix(payload, nil, h.socketAddr); err != nil {
+		return errors.WrapWithDetails(err, "cannot send to journal", "socket", h.socketAddr.Name)
+	}
+
+	return nil
+}
+
+// Close closes the journal connection
+func (h *JournaldHook) Close() error {
+	return h.conn.Close()
+}
+
+// Format builds the native journal protocol payload
+func (h *JournaldHook) Format(entry *log.Entry) ([]byte, error) {
+	entry = h.CheckStack(entry)
+	data := h.MergeDetailsToFields(entry)
+	h.RenderFieldValues(data)
+
+	payload := &bytes.Buffer{}
+	appendJournalField(payload, "MESSAGE", entry.Message)
+	appendJournalField(payload, "PRIORITY", strconv.Itoa(int(h.LevelToSeverity[entry.Level])))
+	if h.Identifier != "" {
+		appendJournalField(payload, "SYSLOG_IDENTIFIER", h.Identifier)
+	}
+	if entry.HasCaller() {
+		appendJournalField(payload, "CODE_FILE", entry.Caller.File)
+		appendJournalField(payload, "CODE_LINE", strconv.Itoa(entry.Caller.Line))
+		appendJournalField(payload, "CODE_FUNC", h.GetPackageTrimmer().TrimFunctionName(entry.Caller.Function))
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	SortingFuncDecorator(AdvancedFieldOrder())(keys)
+	for _, key := range keys {
+		appendJournalField(payload, FixJournalFieldName(JournaldFieldPrefix+key), journalFieldValue(data[key]))
+	}
+
+	if (h.Flags & FlagCallStackInFields) > 0 {
+		if callStackLines := h.GetCallStack(entry); len(callStackLines) > 0 {
+			appendJournalField(payload, FixJournalFieldName(JournaldFieldPrefix+KeyCallStack),
+				strings.Join(callStackLines, "\n"))
+		}
+	}
+
+	return payload.Bytes(), nil
+}
+
+// journalFieldValue renders a field value, strings are written as is, others in JSON
+func journalFieldValue(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+
+	bytes, err := JSONMarshal(value, "", false)
+	if err != nil {
+		return err.Error()
+	}
+	return string(bytes)
+}
+
+/*
+appendJournalField appends a field by the native journal protocol
+	Single-line values: KEY=VALUE\n
+	Multi-line values: KEY\n<64-bit little-endian length>VALUE\n
+*/
+func appendJournalField(payload *bytes.Buffer, key string, value string) {
+	payload.WriteString(key)
+	if strings.ContainsRune(value, '\n') {
+		payload.WriteByte('\n')
+		binary.Write(payload, binary.LittleEndian, uint64(len(value))) // nolint:errcheck,gosec
+	} else {
+		payload.WriteByte('=')
+	}
+	payload.WriteString(value)
+	payload.WriteByte('\n')
+}
+
+/*
+FixJournalFieldName converts to a valid journal field name (uppercase letters, digits and '_')
+	The name should start with a letter (for example JournaldFieldPrefix)
+*/
+func FixJournalFieldName(name string) string {
+	str := strings.Builder{}
+
+	for _, b := range []byte(strings.ToUpper(name)) {
+		if str.Len() >= JournaldFieldMaxLength {
+			break
+		}
+		if (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' {
+			str.WriteByte(b) //nolint:gosec
+		} else {
+			str.WriteByte('_') //nolint:gosec
+		}
+	}
+
+	return str.String()
+}
@@ -0,0 +1,109 @@
+package errfmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// parseJournalPayload decodes the native journal protocol
+func parseJournalPayload(t *testing.T, payload []byte) map[string]string {
+	fields := map[string]string{}
+	for len(payload) > 0 {
+		end := bytes.IndexAny(payload, "=\n")
+		if !assert.True(t, end > 0, "field name") {
+			break
+		}
+		key := string(payload[:end])
+		if payload[end] == '=' {
+			payload = payload[end+1:]
+			lineEnd := bytes.IndexByte(payload, '\n')
+			fields[key] = string(payload[:lineEnd])
+			payload = payload[lineEnd+1:]
+		} else {
+			size := binary.LittleEndian.Uint64(payload[end+1 : end+9])
+			payload = payload[end+9:]
+			fields[key] = string(payload[:size])
+			assert.Equal(t, byte('\n'), payload[size], "multi-line end")
+			payload = payload[size+1:]
+		}
+	}
+
+	return fields
+}
+
+func TestJournaldHook_Fire(t *testing.T) {
+	RegisterSkipPackageFromStackTrace(pkgPathMarker{})
+	funcName := FunctionNameShort()
+	dir, err := ioutil.TempDir("", "journald")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir) // nolint:errcheck
+
+	socketPath := filepath.Join(dir, "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	assert.Nil(t, err)
+	defer server.Close() // nolint:errcheck
+
+	hook, err := NewJournaldHook(FlagExtractDetails|FlagCallStackInFields, 2, socketPath, "application")
+	assert.Nil(t, err)
+	defer hook.Close() // nolint:errcheck
+
+	logger := log.New()
+	logger.ReportCaller = true
+
+	entry := logger.WithError(GenerateDeepErrors())
+	pc, file, line, _ := runtime.Caller(0)
+	entry.Caller = &runtime.Frame{PC: pc, File: file, Line: line, Function: runtime.FuncForPC(pc).Name()}
+	entry.Level = log.ErrorLevel
+	entry.Message = "USER MSG"
+	entry.Time = time.Now()
+	assert.Nil(t, hook.Fire(entry))
+
+	buf := make([]byte, 65536)
+	assert.Nil(t, server.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := server.ReadFromUnix(buf)
+	assert.Nil(t, err)
+	fields := parseJournalPayload(t, buf[:n])
+
+	assert.Equal(t, "USER MSG", fields["MESSAGE"])
+	assert.Equal(t, "3", fields["PRIORITY"])
+	assert.Equal(t, "application", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, file, fields["CODE_FILE"])
+	assert.Equal(t, funcName, fields["CODE_FUNC"])
+	assert.NotEmpty(t, fields["CODE_LINE"])
+	assert.Equal(t, entry.Data[log.ErrorKey].(error).Error(), fields["ERRFMT_ERROR"])
+	assert.Equal(t, "V0_1", fields["ERRFMT_K0_1"])
+	assert.Equal(t, "V3 space", fields["ERRFMT_K3_2"])
+	assert.Equal(t, `V3"doublequote`, fields["ERRFMT_K3_5"])
+	assert.Equal(t, "12", fields["ERRFMT_K5_INT"])
+	assert.Equal(t, `{"1":"ONE","2":"TWO"}`, fields["ERRFMT_K5_MAP"])
+	assert.Equal(t, `errfmt.newWithDetails() errfmt.go:0
+errfmt.GenerateDeepErrors() errfmt.go:0
+`+funcName+`() hook_journald_test.go:0`, replaceCallLine(fields["ERRFMT_CALLSTACK"]))
+}
+
+func TestJournaldHook_NoServer(t *testing.T) {
+	hook, err := NewJournaldHook(FlagNone, 0, filepath.Join(os.TempDir(), "errfmt-no-journald"), "")
+	assert.Nil(t, err)
+	defer hook.Close() // nolint:errcheck
+
+	assert.NotNil(t, hook.Fire(log.NewEntry(log.New())))
+}
+
+func TestFixJournalFieldName(t *testing.T) {
+	assert.Equal(t, "ERRFMT_K3_2", FixJournalFieldName("ERRFMT_K3 2"))
+	assert.Equal(t, "ERRFMT_K3_5", FixJournalFieldName(`ERRFMT_k3"5`))
+	assert.Equal(t, strings.Repeat("X", JournaldFieldMaxLength),
+		FixJournalFieldName(strings.Repeat("x", JournaldFieldMaxLength+1)))
+}
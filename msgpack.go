@@ -0,0 +1,355 @@
+package errfmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+const (
+	// msgpackExtEventTime is the Fluentd EventTime extension type
+	msgpackExtEventTime = 0
+)
+
+// msgpackEventTime is encoded as Fluentd EventTime (ext 0: seconds and nanoseconds, big-endian uint32)
+type msgpackEventTime time.Time
+
+/*
+msgpackEncode appends the MessagePack encoding of the value
+	Supported: nil, bool, numbers, string, []byte, []string, []interface{}, map[string]interface{},
+	log.Fields, json.Number, msgpackEventTime, error (by Error()). Others are converted by a JSON round-trip.
+*/
+func msgpackEncode(buf []byte, value interface{}) []byte { // nolint:gocyclo,funlen
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case bool:
+		if v {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case string:
+		return msgpackEncodeString(buf, v)
+	case []byte:
+		return msgpackEncodeBin(buf, v)
+	case error:
+		return msgpackEncodeString(buf, v.Error())
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return msgpackEncodeInt(buf, n)
+		}
+		n, _ := v.Float64() // nolint:errcheck
+		return appendUint64(append(buf, 0xcb), math.Float64bits(n))
+	case msgpackEventTime:
+		t := time.Time(v)
+		buf = append(buf, 0xd7, msgpackExtEventTime)
+		buf = appendUint32(buf, uint32(t.Unix()))
+		return appendUint32(buf, uint32(t.Nanosecond()))
+	case []string:
+		buf = msgpackEncodeArrayHeader(buf, len(v))
+		for _, item := range v {
+			buf = msgpackEncodeString(buf, item)
+		}
+		return buf
+	case []interface{}:
+		buf = msgpackEncodeArrayHeader(buf, len(v))
+		for _, item := range v {
+			buf = msgpackEncode(buf, item)
+		}
+		return buf
+	case map[string]interface{}:
+		return msgpackEncodeMap(buf, v)
+	}
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return msgpackEncodeInt(buf, val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return msgpackEncodeUint(buf, val.Uint())
+	case reflect.Float32, reflect.Float64:
+		buf = append(buf, 0xcb)
+		return appendUint64(buf, math.Float64bits(val.Float()))
+	case reflect.Map:
+		if val.Type().Key().Kind() == reflect.String && val.Type().Elem().Kind() == reflect.Interface {
+			items := make(map[string]interface{}, val.Len())
+			for _, key := range val.MapKeys() {
+				items[key.String()] = val.MapIndex(key).Interface()
+			}
+			return msgpackEncodeMap(buf, items)
+		}
+	}
+
+	var converted interface{}
+	jsonBytes, err := json.Marshal(value)
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+		decoder.UseNumber()
+		err = decoder.Decode(&converted)
+	}
+	if err != nil {
+		return msgpackEncodeString(buf, err.Error())
+	}
+	return msgpackEncode(buf, converted)
+}
+
+func msgpackEncodeMap(buf []byte, items map[string]interface{}) []byte {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	switch n := len(keys); {
+	case n < 16:
+		buf = append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 0xde)
+		buf = appendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0xdf)
+		buf = appendUint32(buf, uint32(n))
+	}
+	for _, key := range keys {
+		buf = msgpackEncodeString(buf, key)
+		buf = msgpackEncode(buf, items[key])
+	}
+
+	return buf
+}
+
+func msgpackEncodeArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(buf, 0xdc), uint16(n))
+	default:
+		return appendUint32(append(buf, 0xdd), uint32(n))
+	}
+}
+
+func msgpackEncodeString(buf []byte, str string) []byte {
+	switch n := len(str); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = appendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = appendUint32(append(buf, 0xdb), uint32(n))
+	}
+
+	return append(buf, str...)
+}
+
+func msgpackEncodeBin(buf []byte, bin []byte) []byte {
+	switch n := len(bin); {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = appendUint16(append(buf, 0xc5), uint16(n))
+	default:
+		buf = appendUint32(append(buf, 0xc6), uint32(n))
+	}
+
+	return append(buf, bin...)
+}
+
+func msgpackEncodeInt(buf []byte, n int64) []byte {
+	if n >= 0 {
+		return msgpackEncodeUint(buf, uint64(n))
+	}
+	if n >= -32 {
+		return append(buf, byte(n))
+	}
+
+	return appendUint64(append(buf, 0xd3), uint64(n))
+}
+
+func msgpackEncodeUint(buf []byte, n uint64) []byte {
+	if n < 128 {
+		return append(buf, byte(n))
+	}
+
+	return appendUint64(append(buf, 0xcf), n)
+}
+
+func appendUint16(buf []byte, n uint16) []byte {
+	return append(buf, byte(n>>8), byte(n))
+}
+
+func appendUint32(buf []byte, n uint32) []byte {
+	return append(buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendUint64(buf []byte, n uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(n>>32)), uint32(n))
+}
+
+/*
+msgpackDecode reads one MessagePack value
+	Maps are decoded to map[string]interface{}, integers to int64 or uint64,
+	EventTime to time.Time, other extensions to []byte.
+*/
+func msgpackDecode(r *bufio.Reader) (interface{}, error) { // nolint:gocyclo,funlen
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return msgpackDecodeMap(r, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return msgpackDecodeArray(r, int(b&0x0f))
+	case b&0xe0 == 0xa0:
+		return msgpackReadString(r, int(b&0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := msgpackReadLength(r, 1<<(b-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return msgpackReadBytes(r, n)
+	case 0xca:
+		n, err := msgpackReadUint(r, 4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := msgpackReadUint(r, 8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return msgpackReadUint(r, 1<<(b-0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := msgpackReadUint(r, size)
+		shift := uint(64 - 8*size)
+		return int64(n<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return msgpackDecodeExt(r, 1<<(b-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := msgpackReadLength(r, 1<<(b-0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeExt(r, n)
+	case 0xd9, 0xda, 0xdb:
+		n, err := msgpackReadLength(r, 1<<(b-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return msgpackReadString(r, n)
+	case 0xdc, 0xdd:
+		n, err := msgpackReadLength(r, 2<<(b-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeArray(r, n)
+	case 0xde, 0xdf:
+		n, err := msgpackReadLength(r, 2<<(b-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeMap(r, n)
+	}
+
+	return nil, errors.NewWithDetails("invalid MessagePack type", "type", b)
+}
+
+func msgpackDecodeMap(r *bufio.Reader, n int) (map[string]interface{}, error) {
+	items := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		keyString, ok := key.(string)
+		if !ok {
+			return nil, errors.NewWithDetails("invalid MessagePack map key", "key", key)
+		}
+		if items[keyString], err = msgpackDecode(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+func msgpackDecodeArray(r *bufio.Reader, n int) ([]interface{}, error) {
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+
+	return items, nil
+}
+
+func msgpackDecodeExt(r *bufio.Reader, n int) (interface{}, error) {
+	extType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := msgpackReadBytes(r, n)
+	if err != nil {
+		return nil, err
+	}
+	if extType == msgpackExtEventTime && n == 8 {
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), int64(binary.BigEndian.Uint32(data[4:]))), nil
+	}
+
+	return data, nil
+}
+
+func msgpackReadString(r *bufio.Reader, n int) (string, error) {
+	data, err := msgpackReadBytes(r, n)
+	return string(data), err
+}
+
+func msgpackReadBytes(r *bufio.Reader, n int) ([]byte, error) {
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+func msgpackReadLength(r *bufio.Reader, size int) (int, error) {
+	n, err := msgpackReadUint(r, size)
+	return int(n), err
+}
+
+func msgpackReadUint(r *bufio.Reader, size int) (uint64, error) {
+	data, err := msgpackReadBytes(r, size)
+	if err != nil {
+		return 0, err
+	}
+
+	n := uint64(0)
+	for _, b := range data {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
@@ -0,0 +1,57 @@
+package errfmt
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestMsgpack_RoundTrip(t *testing.T) {
+	ts := time.Unix(1571170345, 123456789)
+	value := []interface{}{
+		nil, true, false,
+		0, 127, 128, -1, -32, -33, int64(-1) << 40, uint64(1) << 63,
+		1.5, float32(2.5),
+		"", strings.Repeat("s", 31), strings.Repeat("s", 32), strings.Repeat("s", 256), strings.Repeat("s", 70000),
+		[]byte{1, 2, 3},
+		[]string{"a", "b"},
+		make([]interface{}, 20),
+		log.Fields{"K": "V"},
+		map[int]string{1: "ONE"},
+		struct{ Text string }{Text: "text"},
+		msgpackEventTime(ts),
+	}
+
+	decoded, err := msgpackDecode(bufio.NewReader(bytes.NewReader(msgpackEncode(nil, value))))
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		nil, true, false,
+		int64(0), int64(127), uint64(128), int64(-1), int64(-32), int64(-33), int64(-1) << 40, uint64(1) << 63,
+		1.5, 2.5,
+		"", strings.Repeat("s", 31), strings.Repeat("s", 32), strings.Repeat("s", 256), strings.Repeat("s", 70000),
+		[]byte{1, 2, 3},
+		[]interface{}{"a", "b"},
+		make([]interface{}, 20),
+		map[string]interface{}{"K": "V"},
+		map[string]interface{}{"1": "ONE"},
+		map[string]interface{}{"Text": "text"},
+		ts,
+	}, decoded)
+}
+
+func TestMsgpack_Invalid(t *testing.T) {
+	_, err := msgpackDecode(bufio.NewReader(bytes.NewReader([]byte{0xc1})))
+	assert.NotNil(t, err)
+
+	_, err = msgpackDecode(bufio.NewReader(bytes.NewReader([]byte{0x81, 0x01, 0x01})))
+	assert.NotNil(t, err, "non-string key")
+
+	_, err = msgpackDecode(bufio.NewReader(bytes.NewReader([]byte{0xa5, 'a'})))
+	assert.NotNil(t, err, "truncated")
+}
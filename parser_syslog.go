@@ -0,0 +1,303 @@
+package errfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/juju/rfc/rfc5424"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// syslogNilValue is the NILVALUE of RFC5424
+	syslogNilValue = "-"
+	// syslogBOM is the optional UTF-8 BOM before MSG
+	syslogBOM = "\xEF\xBB\xBF"
+)
+
+/*
+ParsedMessage is a parsed RFC5424 message, written by AdvancedSyslogFormatter
+	Fields is built from the StructuredIDDetails element
+	CallStack is built from the StructuredIDCallStack element or from the console lines
+*/
+type ParsedMessage struct {
+	Header         rfc5424.Header
+	StructuredData []ParsedDataElement
+	Msg            string
+	Fields         log.Fields
+	CallStack      []string
+}
+
+// ParsedDataElement is a parsed SD-ELEMENT
+type ParsedDataElement struct {
+	ID     string
+	Params []ParsedDataParam
+}
+
+// ParsedDataParam is a parsed SD-PARAM
+type ParsedDataParam struct {
+	Name string
+	// Raw is the unescaped PARAM-VALUE
+	Raw string
+	// Value is the JSON-decoded Raw (numbers are json.Number), or Raw, if it's not a valid JSON
+	Value interface{}
+}
+
+/*
+ParseSyslogMessage parses a message, written by AdvancedSyslogFormatter
+	Values trimmed by FlagTrimJSONDquote are decoded as JSON, if possible (for example "true" becomes bool),
+	otherwise as JSON string content.
+*/
+func ParseSyslogMessage(text []byte) (*ParsedMessage, error) {
+	lines := strings.Split(strings.TrimRight(string(text), "\n"), "\n")
+	parser := syslogParser{text: lines[0]}
+	message := &ParsedMessage{Fields: log.Fields{}, CallStack: []string{}}
+
+	if err := parser.parseHeader(&message.Header); err != nil {
+		return nil, err
+	}
+
+	sd, err := parser.parseStructuredData()
+	if err != nil {
+		return nil, err
+	}
+	message.StructuredData = sd
+
+	if parser.pos < len(parser.text) {
+		if parser.text[parser.pos] != ' ' {
+			return nil, parser.errorf("missing SP before MSG")
+		}
+		message.Msg = strings.TrimPrefix(parser.text[parser.pos+1:], syslogBOM)
+	}
+
+	for _, element := range message.StructuredData {
+		for _, param := range element.Params {
+			switch element.ID {
+			case StructuredIDDetails:
+				message.Fields[param.Name] = param.Value
+			case StructuredIDCallStack:
+				if param.Name == KeyCallStack {
+					message.CallStack = toStringSlice(param.Value)
+				}
+			}
+		}
+	}
+
+	if len(message.CallStack) == 0 {
+		for _, line := range lines[1:] {
+			if strings.HasPrefix(line, "\t") {
+				message.CallStack = append(message.CallStack, line[1:])
+			}
+		}
+	}
+
+	return message, nil
+}
+
+// syslogParser is a simple cursor on the first line of the message
+type syslogParser struct {
+	text string
+	pos  int
+}
+
+func (p *syslogParser) errorf(format string, args ...interface{}) error {
+	return errors.WithDetails(errors.Errorf(format, args...), "pos", p.pos)
+}
+
+// nextToken reads until the next SP and skips it
+func (p *syslogParser) nextToken(name string) (string, error) {
+	end := strings.IndexByte(p.text[p.pos:], ' ')
+	if end < 0 {
+		return "", p.errorf("missing %s", name)
+	}
+	token := p.text[p.pos : p.pos+end]
+	p.pos += end + 1
+	if token == "" {
+		return "", p.errorf("empty %s", name)
+	}
+
+	return token, nil
+}
+
+func (p *syslogParser) parseHeader(header *rfc5424.Header) error {
+	end := strings.IndexByte(p.text, '>')
+	if !strings.HasPrefix(p.text, "<") || end < 0 {
+		return p.errorf("missing PRI")
+	}
+	priority, err := rfc5424.ParsePriority(p.text[:end+1])
+	if err != nil {
+		return errors.WrapWithDetails(err, "invalid PRI", "pos", p.pos)
+	}
+	header.Priority = priority
+	p.pos = end + 1
+
+	version, err := p.nextToken("VERSION")
+	if err != nil {
+		return err
+	}
+	if version != strconv.Itoa(rfc5424.ProtocolVersion) {
+		return p.errorf("unsupported VERSION %s", version)
+	}
+
+	timestamp, err := p.nextToken("TIMESTAMP")
+	if err != nil {
+		return err
+	}
+	if timestamp != syslogNilValue {
+		ts, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return errors.WrapWithDetails(err, "invalid TIMESTAMP", "pos", p.pos)
+		}
+		header.Timestamp = rfc5424.Timestamp{Time: ts}
+	}
+
+	fields := []string{}
+	for _, name := range []string{"HOSTNAME", "APP-NAME", "PROCID", "MSGID"} {
+		field, err := p.nextToken(name)
+		if err != nil {
+			return err
+		}
+		if field == syslogNilValue {
+			field = ""
+		}
+		fields = append(fields, field)
+	}
+	header.Hostname = rfc5424.Hostname{FQDN: fields[0]}
+	header.AppName = rfc5424.AppName(fields[1])
+	header.ProcID = rfc5424.ProcID(fields[2])
+	header.MsgID = rfc5424.MsgID(fields[3])
+
+	return nil
+}
+
+func (p *syslogParser) parseStructuredData() ([]ParsedDataElement, error) {
+	elements := []ParsedDataElement{}
+	if strings.HasPrefix(p.text[p.pos:], syslogNilValue) {
+		p.pos += len(syslogNilValue)
+		return elements, nil
+	}
+
+	for p.pos < len(p.text) && p.text[p.pos] == '[' {
+		element, err := p.parseDataElement()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	if len(elements) == 0 {
+		return nil, p.errorf("missing STRUCTURED-DATA")
+	}
+
+	return elements, nil
+}
+
+func (p *syslogParser) parseDataElement() (ParsedDataElement, error) {
+	element := ParsedDataElement{}
+	p.pos++ // '['
+
+	end := strings.IndexAny(p.text[p.pos:], " ]")
+	if end <= 0 {
+		return element, p.errorf("invalid SD-ID")
+	}
+	element.ID = p.text[p.pos : p.pos+end]
+	p.pos += end
+
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+		param, err := p.parseDataParam()
+		if err != nil {
+			return element, err
+		}
+		element.Params = append(element.Params, param)
+	}
+
+	if p.pos >= len(p.text) || p.text[p.pos] != ']' {
+		return element, p.errorf("unterminated SD-ELEMENT %s", element.ID)
+	}
+	p.pos++
+
+	return element, nil
+}
+
+func (p *syslogParser) parseDataParam() (ParsedDataParam, error) {
+	param := ParsedDataParam{}
+
+	end := strings.Index(p.text[p.pos:], `="`)
+	if end <= 0 {
+		return param, p.errorf("invalid SD-PARAM")
+	}
+	param.Name = p.text[p.pos : p.pos+end]
+	p.pos += end + 2
+
+	raw := strings.Builder{}
+	for ; p.pos < len(p.text); p.pos++ {
+		b := p.text[p.pos]
+		if b == '"' {
+			p.pos++
+			param.Raw = raw.String()
+			param.Value = decodeJSONValue(param.Raw)
+
+			return param, nil
+		}
+		if b == '\\' && p.pos+1 < len(p.text) {
+			if next := p.text[p.pos+1]; next == '\\' || next == '"' || next == ']' {
+				b = next
+				p.pos++
+			}
+		}
+		raw.WriteByte(b) //nolint:gosec
+	}
+
+	return param, p.errorf("unterminated PARAM-VALUE %s", param.Name)
+}
+
+/*
+decodeJSONValue decodes JSON value
+	If it's not a valid JSON, tries to decode as a trimmed JSON string (FlagTrimJSONDquote),
+	or returns the raw string
+*/
+func decodeJSONValue(raw string) interface{} {
+	if value, err := decodeJSON(raw); err == nil {
+		return value
+	}
+	if value, err := decodeJSON(`"` + raw + `"`); err == nil {
+		return value
+	}
+
+	return raw
+}
+
+// decodeJSON decodes exactly one JSON value
+func decodeJSON(raw string) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("trailing data after JSON value")
+	}
+
+	return value, nil
+}
+
+// toStringSlice converts a decoded JSON array to []string
+func toStringSlice(value interface{}) []string {
+	lines := []string{}
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			if line, ok := item.(string); ok {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	return lines
+}
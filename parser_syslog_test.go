@@ -0,0 +1,113 @@
+package errfmt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/juju/rfc/rfc5424"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestParseSyslogMessage_CallStackInFields(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newSyslogLoggerMock(
+		FlagExtractDetails|FlagCallStackInFields,
+		2)
+	ts := time.Now()
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).WithTime(ts).Log(log.ErrorLevel, "USER MSG")
+
+	message, parseErr := ParseSyslogMessage(loggerMock.outBuf.Bytes())
+	assert.Nil(t, parseErr)
+
+	assert.Equal(t, rfc5424.SeverityError, message.Header.Priority.Severity)
+	assert.Equal(t, rfc5424.FacilityDaemon, message.Header.Priority.Facility)
+	assert.True(t, ts.Equal(message.Header.Timestamp.Time))
+	assert.Equal(t, "fqdn.host.com", message.Header.Hostname.String())
+	assert.Equal(t, rfc5424.AppName("application"), message.Header.AppName)
+	assert.Equal(t, rfc5424.ProcID("PID"), message.Header.ProcID)
+	assert.Equal(t, rfc5424.MsgID(MsgIDDetailsCalls), message.Header.MsgID)
+	assert.Equal(t, "USER MSG", message.Msg)
+
+	assert.Len(t, message.StructuredData, 2)
+	assert.Equal(t, StructuredIDDetails, message.StructuredData[0].ID)
+	assert.Equal(t, StructuredIDCallStack, message.StructuredData[1].ID)
+
+	assert.Equal(t, "error", message.Fields[log.FieldKeyLevel])
+	assert.Equal(t, funcName, message.Fields[log.FieldKeyFunc])
+	assert.Equal(t, err.Error(), message.Fields[log.ErrorKey])
+	assert.Equal(t, "V3 space", message.Fields["K3_2"])
+	assert.Equal(t, `V3"doublequote`, message.Fields["K3_5"])
+	assert.Equal(t, true, message.Fields["K5_bool"])
+	assert.Equal(t, json.Number("12"), message.Fields["K5_int"])
+	assert.Equal(t, map[string]interface{}{"1": "ONE", "2": "TWO"}, message.Fields["K5_map"])
+	assert.Equal(t, map[string]interface{}{
+		"Text": "text", "Integer": json.Number("42"), "Bool": true,
+	}, message.Fields["K5_struct"])
+
+	for i := range message.CallStack {
+		message.CallStack[i] = replaceCallLine(message.CallStack[i])
+	}
+	assert.Equal(t, []string{
+		"errfmt.newWithDetails() errfmt.go:0",
+		"errfmt.GenerateDeepErrors() errfmt.go:0",
+		funcName + "() parser_syslog_test.go:0",
+	}, message.CallStack)
+}
+
+func TestParseSyslogMessage_CallStackOnConsole(t *testing.T) {
+	funcName := FunctionNameShort()
+	loggerMock := newSyslogLoggerMock(
+		FlagExtractDetails|FlagCallStackOnConsole|FlagTrimJSONDquote,
+		2)
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).Log(log.ErrorLevel, "USER MSG")
+
+	message, parseErr := ParseSyslogMessage(loggerMock.outBuf.Bytes())
+	assert.Nil(t, parseErr)
+
+	assert.Equal(t, rfc5424.MsgID(MsgIDDetails), message.Header.MsgID)
+	assert.Len(t, message.StructuredData, 1)
+	assert.Equal(t, "V0_1", message.Fields["K0_1"])
+	assert.Equal(t, "V0_1", message.StructuredData[0].Params[4].Raw)
+	assert.Equal(t, err.Error(), message.Fields[log.ErrorKey])
+	assert.Equal(t, "USER MSG", message.Msg)
+	assert.Equal(t, funcName+"() parser_syslog_test.go:0", replaceCallLine(message.CallStack[2]))
+}
+
+func TestParseSyslogMessage_Nil(t *testing.T) {
+	message, err := ParseSyslogMessage([]byte(`<14>1 - - - - - -`))
+	assert.Nil(t, err)
+	assert.True(t, message.Header.Timestamp.IsZero())
+	assert.Equal(t, "-", message.Header.Hostname.String())
+	assert.Empty(t, message.StructuredData)
+	assert.Empty(t, message.Msg)
+
+	message, err = ParseSyslogMessage([]byte(`<14>1 - - - - - [raw a="x\]y\\\"z" b="1 2"] MSG`))
+	assert.Nil(t, err)
+	assert.Equal(t, `x]y\"z`, message.StructuredData[0].Params[0].Raw)
+	assert.Equal(t, "1 2", message.StructuredData[0].Params[1].Value)
+	assert.Empty(t, message.Fields)
+	assert.Equal(t, "MSG", message.Msg)
+}
+
+func TestParseSyslogMessage_Invalid(t *testing.T) {
+	for _, text := range []string{
+		``,
+		`14>1 - - - - - -`,
+		`<14>2 - - - - - -`,
+		`<14>1 NOW - - - - -`,
+		`<14>1 - - - - -`,
+		`<14>1 - - - - - [details a="b"`,
+		`<14>1 - - - - - [details a=b]`,
+		`<14>1 - - - - - [details a="b"]MSG`,
+	} {
+		_, err := ParseSyslogMessage([]byte(text))
+		assert.NotNil(t, err, text)
+	}
+}
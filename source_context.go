@@ -0,0 +1,103 @@
+package errfmt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// SourceContextSize is the default number of source lines before and after the line of the frame
+	SourceContextSize = 2
+	// SourceCacheSize is the max. number of cached source files
+	SourceCacheSize = 64
+)
+
+// sourceCache caches the lines of source files (nil, if the file is unavailable)
+var sourceCache = struct { // nolint:gochecknoglobals
+	sync.Mutex
+	files map[string][]string
+}{files: map[string][]string{}}
+
+/*
+SourceContext returns the source lines around the line of the frame
+	Format: marker ('>' at the line of the frame), line number, source line (tabs are expanded).
+	Returns empty, if the source file is unavailable (for example trimmed builds).
+*/
+func SourceContext(frame CallStackFrame, size int) []string {
+	lines := readSourceLines(frame.Path)
+	if frame.Line <= 0 || frame.Line > len(lines) {
+		return []string{}
+	}
+
+	first := frame.Line - size
+	if first < 1 {
+		first = 1
+	}
+	last := frame.Line + size
+	if last > len(lines) {
+		last = len(lines)
+	}
+
+	snippet := make([]string, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == frame.Line {
+			marker = ">"
+		}
+		snippet = append(snippet, fmt.Sprintf("  %s %4d | %s",
+			marker, n, strings.ReplaceAll(lines[n-1], "\t", "    ")))
+	}
+
+	return snippet
+}
+
+// buildCallStackSourceLines renders the call stack lines, followed by the source context of application frames
+func buildCallStackSourceLines(callStackFrames []CallStackFrame, size int,
+	renderer *CallStackRenderer, trimmer *PackageTrimmer,
+) []string {
+	if len(callStackFrames) == 0 {
+		return []string{}
+	}
+
+	callStackLines := append(make([]string, 0, len(callStackFrames)), renderer.Header...)
+	for _, frame := range callStackFrames {
+		callStackLines = append(callStackLines, renderer.RenderFrame(frame, trimmer)...)
+		if frame.Elided == 0 && trimmer.IsApplicationFunction(frame.Function) {
+			callStackLines = append(callStackLines, SourceContext(frame, size)...)
+		}
+	}
+
+	return callStackLines
+}
+
+// readSourceLines reads the lines of the source file by the cache
+func readSourceLines(path string) []string {
+	sourceCache.Lock()
+	defer sourceCache.Unlock()
+
+	if lines, ok := sourceCache.files[path]; ok {
+		return lines
+	}
+
+	var lines []string
+	if file, err := os.Open(path); err == nil { // nolint:gosec
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if scanner.Err() != nil {
+			lines = nil
+		}
+		file.Close() // nolint:errcheck,gosec
+	}
+
+	if len(sourceCache.files) >= SourceCacheSize {
+		sourceCache.files = map[string][]string{}
+	}
+	sourceCache.files[path] = lines
+
+	return lines
+}
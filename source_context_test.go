@@ -0,0 +1,50 @@
+package errfmt
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSourceContext_Text(t *testing.T) {
+	loggerMock := newTextLoggerMock(FlagCallStackOnConsole|FlagCallStackSourceContext, 2)
+	GetAdvancedFormatter(loggerMock.Formatter).SourceContextSize = 1
+
+	err := GenerateDeepErrors()
+	loggerMock.WithError(err).Log(log.ErrorLevel, "USER MSG")
+
+	if debugTest {
+		fmt.Printf("###\n%s\n###\n", loggerMock.outBuf.String())
+	}
+	text := loggerMock.outBuf.String()
+	assert.Contains(t, text, "\terrfmt.newWithDetails() errfmt.go:")
+	assert.Regexp(t, `\n\t  >\s+\d+ \|     return errors.WrapWithDetails\(err, "MESSAGE%0"`, text)
+	assert.Regexp(t, `\n\t  >\s+\d+ \|     err := GenerateDeepErrors\(\)\n`, text)
+	assert.Equal(t, 3, strings.Count(text, "\t  >"))
+}
+
+func TestSourceContext_HTTPProblem(t *testing.T) {
+	loggerMock := newTextLoggerMock(FlagCallStackInHTTPProblem|FlagCallStackSourceContext, 2)
+
+	httpProblem := BuildHTTPProblem(http.StatusInternalServerError, loggerMock.WithError(GenerateDeepErrors()))
+	assert.Regexp(t, `^errfmt.newWithDetails\(\) errfmt.go:\d+$`, httpProblem.CallStack[0])
+	assert.Regexp(t, `^  >\s+\d+ \|     return errors.WrapWithDetails`, httpProblem.CallStack[1+SourceContextSize])
+	markers := 0
+	for _, line := range httpProblem.CallStack {
+		if strings.HasPrefix(line, "  >") {
+			markers++
+		}
+	}
+	assert.Equal(t, 3, markers)
+}
+
+func TestSourceContext_Missing(t *testing.T) {
+	frame := CallStackFrame{Function: "main.main", Path: "/not/existing/main.go", Line: 3}
+	assert.Empty(t, SourceContext(frame, 2))
+	assert.Equal(t, []string{"main.main() main.go:3"}, buildCallStackSourceLines([]CallStackFrame{frame}, 2, CompactCallStackRenderer(), DefaultPackageTrimmer()))
+}
@@ -0,0 +1,191 @@
+package errfmt
+
+import (
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// SourceURLDevelVersion is the version of the main module, if it's unknown (for example "(devel)")
+	SourceURLDevelVersion = "HEAD"
+)
+
+// pseudoVersionRevision matches the commit hash of pseudo-versions
+var pseudoVersionRevision = regexp.MustCompile(`^v[0-9.]+-(?:[0-9a-z.]+\.)?[0-9]{14}-([0-9a-f]{12})(?:\+incompatible)?$`) // nolint:gochecknoglobals,lll
+
+// sourceModules are the known modules, loaded from the build info (copy-on-write, readers are lock-free)
+var sourceModules = struct { // nolint:gochecknoglobals
+	sync.Mutex // serializes the writers
+	loadOnce   sync.Once
+	table      atomic.Value // *sourceModuleTable
+}{}
+
+// sourceModuleTable is an immutable snapshot of the known modules
+type sourceModuleTable struct {
+	versions map[string]string // module path - version
+	paths    []string          // longest first
+}
+
+// CallStackLink is a call stack line with the source URL of the frame
+type CallStackLink struct {
+	Frame string `json:"frame"`
+	URL   string `json:"url,omitempty"`
+}
+
+/*
+ResolveSourceURL resolves the source URL of the frame by the template
+	Template variables: {module}, {version}, {path} (relative to the module root),
+	{file} (file name), {line}.
+	The modules and versions are read from runtime/debug.ReadBuildInfo, the version
+	of the main module is the VCS revision (if available, see RegisterSourceModule).
+	Returns empty, if the module of the frame is unknown (for example standard library).
+*/
+func ResolveSourceURL(template string, frame CallStackFrame) string {
+	if template == "" || frame.Elided > 0 || frame.Function == "" {
+		return ""
+	}
+
+	pkgPath := packagePathOfFunction(frame.Function)
+	modulePath, version, ok := findSourceModule(pkgPath)
+	if !ok {
+		return ""
+	}
+
+	fileName := frame.Path[strings.LastIndex(frame.Path, "/")+1:]
+	filePath := fileName
+	if pkgPath != modulePath {
+		filePath = strings.TrimPrefix(pkgPath, modulePath+"/") + "/" + fileName
+	}
+
+	return strings.NewReplacer(
+		"{module}", modulePath,
+		"{version}", version,
+		"{path}", filePath,
+		"{file}", fileName,
+		"{line}", strconv.Itoa(frame.Line),
+	).Replace(template)
+}
+
+// RegisterSourceModule registers (or overrides) the version of a module for ResolveSourceURL
+func RegisterSourceModule(modulePath string, version string) {
+	loadSourceModules()
+
+	sourceModules.Lock()
+	defer sourceModules.Unlock()
+
+	table := loadSourceModuleTable().clone()
+	table.add(modulePath, version)
+	sourceModules.table.Store(table)
+}
+
+// buildCallStackLinks builds the compact call stack lines with source URLs
+func buildCallStackLinks(callStackFrames []CallStackFrame, template string, trimmer *PackageTrimmer) []CallStackLink {
+	callStackLinks := make([]CallStackLink, 0, len(callStackFrames))
+	for _, frame := range callStackFrames {
+		callStackLinks = append(callStackLinks, CallStackLink{
+			Frame: trimmer.FrameString(frame),
+			URL:   ResolveSourceURL(template, frame),
+		})
+	}
+
+	return callStackLinks
+}
+
+// findSourceModule finds the module of the package (longest matching module path)
+func findSourceModule(pkgPath string) (string, string, bool) {
+	loadSourceModules()
+
+	table := loadSourceModuleTable()
+	for _, modulePath := range table.paths {
+		if pkgPath == modulePath || strings.HasPrefix(pkgPath, modulePath+"/") {
+			return modulePath, table.versions[modulePath], true
+		}
+	}
+
+	return "", "", false
+}
+
+// loadSourceModules loads the modules from the build info (once)
+func loadSourceModules() {
+	sourceModules.loadOnce.Do(func() {
+		buildInfo, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+
+		sourceModules.Lock()
+		defer sourceModules.Unlock()
+
+		table := loadSourceModuleTable().clone()
+		for _, dep := range buildInfo.Deps {
+			version := dep.Version
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				version = dep.Replace.Version
+			}
+			if match := pseudoVersionRevision.FindStringSubmatch(version); match != nil {
+				version = match[1]
+			}
+			table.add(dep.Path, version)
+		}
+
+		if buildInfo.Main.Path != "" {
+			version := vcsRevision(buildInfo)
+			if version == "" {
+				version = buildInfo.Main.Version
+			}
+			if version == "" || version == "(devel)" {
+				version = SourceURLDevelVersion
+			}
+			table.add(buildInfo.Main.Path, version)
+		}
+		sourceModules.table.Store(table)
+	})
+}
+
+// loadSourceModuleTable returns the current snapshot of the known modules
+func loadSourceModuleTable() *sourceModuleTable {
+	if table, ok := sourceModules.table.Load().(*sourceModuleTable); ok {
+		return table
+	}
+
+	return &sourceModuleTable{versions: map[string]string{}}
+}
+
+// clone makes a modifiable copy of the table
+func (table *sourceModuleTable) clone() *sourceModuleTable {
+	versions := make(map[string]string, len(table.versions)+1)
+	for modulePath, version := range table.versions {
+		versions[modulePath] = version
+	}
+
+	return &sourceModuleTable{
+		versions: versions,
+		paths:    append(make([]string, 0, len(table.paths)+1), table.paths...),
+	}
+}
+
+// add adds (or overrides) the module, the table must not be published yet
+func (table *sourceModuleTable) add(modulePath string, version string) {
+	if _, ok := table.versions[modulePath]; !ok {
+		table.paths = append(table.paths, modulePath)
+		sort.Slice(table.paths, func(i, j int) bool {
+			return len(table.paths[i]) > len(table.paths[j])
+		})
+	}
+	table.versions[modulePath] = version
+}
+
+// packagePathOfFunction returns the package path of the full function name
+func packagePathOfFunction(functionName string) string {
+	slash := strings.LastIndex(functionName, "/")
+	if dot := strings.Index(functionName[slash+1:], "."); dot >= 0 {
+		return functionName[:slash+1+dot]
+	}
+
+	return functionName
+}
@@ -0,0 +1,11 @@
+//go:build !go1.18
+// +build !go1.18
+
+package errfmt
+
+import "runtime/debug"
+
+// vcsRevision returns the VCS revision of the main module (not available before Go 1.18)
+func vcsRevision(buildInfo *debug.BuildInfo) string {
+	return ""
+}
@@ -0,0 +1,17 @@
+//go:build go1.18
+// +build go1.18
+
+package errfmt
+
+import "runtime/debug"
+
+// vcsRevision returns the VCS revision of the main module (empty, if unknown)
+func vcsRevision(buildInfo *debug.BuildInfo) string {
+	for _, setting := range buildInfo.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,52 @@
+package errfmt
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSourceURLTemplate = "https://git.example.com/{module}/blob/{version}/{path}#L{line}"
+
+func TestResolveSourceURL(t *testing.T) {
+	RegisterSourceModule("github.com/pgillich/errfmt", "v1.2.3")
+	RegisterSourceModule("example.com/mod", "v0.1.0")
+	RegisterSourceModule("example.com/mod/v2", "abcdef123456")
+
+	assert.Equal(t, "https://git.example.com/example.com/mod/v2/blob/abcdef123456/sub/pkg/file.go#L12",
+		ResolveSourceURL(testSourceURLTemplate, CallStackFrame{
+			Function: "example.com/mod/v2/sub/pkg.(*T).Method", Path: "/any/where/file.go", Line: 12,
+		}))
+	assert.Equal(t, "https://git.example.com/example.com/mod/blob/v0.1.0/main.go#L3",
+		ResolveSourceURL(testSourceURLTemplate, CallStackFrame{Function: "example.com/mod.main", Path: "/src/main.go", Line: 3}))
+	assert.Empty(t, ResolveSourceURL(testSourceURLTemplate, CallStackFrame{Function: "runtime.goexit", Path: "/go/asm.s"}))
+	assert.Empty(t, ResolveSourceURL(testSourceURLTemplate, CallStackFrame{Elided: 2}))
+	assert.Empty(t, ResolveSourceURL("", CallStackFrame{Function: "example.com/mod.main"}))
+
+	assert.Equal(t, "example.com/mod/v2/sub/pkg", packagePathOfFunction("example.com/mod/v2/sub/pkg.(*T).Method"))
+	assert.Equal(t, "main", packagePathOfFunction("main.main"))
+}
+
+func TestSourceURL_JSON_HTTPProblem(t *testing.T) {
+	RegisterSourceModule("github.com/pgillich/errfmt", "v1.2.3")
+	loggerMock := newJSONLoggerMock(FlagCallStackInFields|FlagCallStackInHTTPProblem, 2)
+	GetAdvancedFormatter(loggerMock.Formatter).SourceURLTemplate = testSourceURLTemplate
+
+	loggerMock.WithError(GenerateDeepErrors()).Error("USER MSG")
+	message := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(loggerMock.outBuf.Bytes(), &message))
+	callStack, ok := message[KeyCallStack].([]interface{})
+	assert.True(t, ok, KeyCallStack)
+	assert.Len(t, callStack, 3)
+	first, ok := callStack[0].(map[string]interface{})
+	assert.True(t, ok, "link")
+	assert.Regexp(t, `^errfmt.newWithDetails\(\) errfmt.go:\d+$`, first["frame"])
+	assert.Regexp(t, `^https://git.example.com/github.com/pgillich/errfmt/blob/v1.2.3/errfmt.go#L\d+$`, first["url"])
+
+	httpProblem := BuildHTTPProblem(http.StatusInternalServerError, loggerMock.WithError(GenerateDeepErrors()))
+	assert.Len(t, httpProblem.CallStack, 3)
+	assert.Len(t, httpProblem.CallStackLinks, 3)
+	assert.Regexp(t, `/blob/v1.2.3/source_url_test.go#L\d+$`, httpProblem.CallStackLinks[2].URL)
+}
@@ -0,0 +1,149 @@
+package errfmt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// KeyStackID is the field name of the call stack ID (see StackRegistry)
+	KeyStackID = "stack_id"
+	// StackIDLength is the length of the call stack ID (hex digits)
+	StackIDLength = 8
+	// StackRegistrySize is the default max. number of registered call stacks
+	StackRegistrySize = 4096
+)
+
+/*
+StackRegistry records the seen call stacks, see AdvancedFormatter.StackRegistry
+	The full call stack is printed only at the first occurrence of a call stack
+	(per process, or per Window, if positive), later occurrences are referenced by the
+	call stack ID field (stack_id). Only the first seen time is kept per call stack,
+	expired call stacks are removed, the registry is cleared, if more than MaxStacks
+	call stacks are registered. Each Format registers the call stack once (see AdvancedFormatter.CheckStack),
+	so each output (formatter or hook) needs its own StackRegistry: hooks format the entry
+	before the formatter of the logger, a shared registry would suppress the call stack there.
+*/
+type StackRegistry struct {
+	Window time.Duration
+	// MaxStacks is the max. number of registered call stacks, StackRegistrySize if not positive
+	MaxStacks int
+
+	mu        sync.Mutex
+	stacks    map[string]time.Time // first seen
+	lastPrune time.Time
+}
+
+// NewStackRegistry makes a new StackRegistry, window 0 means per process
+func NewStackRegistry(window time.Duration) *StackRegistry {
+	return &StackRegistry{
+		Window:    window,
+		MaxStacks: StackRegistrySize,
+		stacks:    map[string]time.Time{},
+	}
+}
+
+/*
+Check registers the call stack of the entry
+	Returns the call stack ID and true, if it's the first occurrence (in the Window).
+	Each call is an occurrence, see AdvancedFormatter.CheckStack.
+*/
+func (r *StackRegistry) Check(entry *log.Entry, callStackFrames []CallStackFrame) (string, bool) {
+	stackID := StackID(callStackFrames)
+	now := entry.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if firstSeen, ok := r.stacks[stackID]; ok && !r.expired(firstSeen, now) {
+		return stackID, false
+	}
+
+	r.prune(now)
+	r.stacks[stackID] = now
+
+	return stackID, true
+}
+
+// Len returns the number of registered call stacks
+func (r *StackRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.stacks)
+}
+
+// expired returns true, if the Window of the first occurrence is over
+func (r *StackRegistry) expired(firstSeen time.Time, now time.Time) bool {
+	return r.Window > 0 && now.Sub(firstSeen) >= r.Window
+}
+
+// prune removes the expired call stacks (at most once per Window) and clears the full registry, must be called with locked mu
+func (r *StackRegistry) prune(now time.Time) {
+	maxStacks := r.MaxStacks
+	if maxStacks <= 0 {
+		maxStacks = StackRegistrySize
+	}
+
+	if r.Window > 0 && (len(r.stacks) >= maxStacks || now.Sub(r.lastPrune) >= r.Window) {
+		r.lastPrune = now
+		for stackID, firstSeen := range r.stacks {
+			if r.expired(firstSeen, now) {
+				delete(r.stacks, stackID)
+			}
+		}
+	}
+	if len(r.stacks) >= maxStacks {
+		r.stacks = map[string]time.Time{}
+	}
+}
+
+// stackCheckKey is the context key of stackCheck in the formatted entry
+type stackCheckKey struct{}
+
+// stackCheck is the call stack decision of a Format call (see AdvancedFormatter.CheckStack)
+type stackCheck struct {
+	stackID string
+	printed bool
+}
+
+// withStackCheck returns a copy of the entry with the call stack decision in its Context
+func withStackCheck(entry *log.Entry, check stackCheck) *log.Entry {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	checked := *entry
+	checked.Context = context.WithValue(ctx, stackCheckKey{}, check)
+
+	return &checked
+}
+
+// getStackCheck returns the call stack decision of the entry, if it's checked
+func getStackCheck(entry *log.Entry) (stackCheck, bool) {
+	if entry.Context == nil {
+		return stackCheck{}, false
+	}
+	check, ok := entry.Context.Value(stackCheckKey{}).(stackCheck)
+
+	return check, ok
+}
+
+// StackID computes the short ID of the call stack (from functions, paths and lines)
+func StackID(callStackFrames []CallStackFrame) string {
+	hash := sha256.New()
+	for _, frame := range callStackFrames {
+		fmt.Fprintf(hash, "%s\n%s:%d\n", frame.Function, frame.Path, frame.Line)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:StackIDLength]
+}
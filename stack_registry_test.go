@@ -0,0 +1,111 @@
+package errfmt
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestStackRegistry_Text(t *testing.T) {
+	loggerMock := newTextLoggerMock(FlagCallStackOnConsole, 0)
+	registry := NewStackRegistry(0)
+	GetAdvancedFormatter(loggerMock.Formatter).StackRegistry = registry
+
+	outputs := []string{}
+	for i := 0; i < 2; i++ {
+		loggerMock.outBuf.Reset()
+		loggerMock.WithError(GenerateDeepErrors()).Error("USER MSG")
+		outputs = append(outputs, loggerMock.outBuf.String())
+	}
+	loggerMock.outBuf.Reset()
+	loggerMock.WithError(GenerateDeepErrors()).Error("OTHER LINE")
+	outputs = append(outputs, loggerMock.outBuf.String())
+
+	stackID := regexp.MustCompile(KeyStackID + `=([0-9a-f]+)`).FindStringSubmatch(outputs[0])[1]
+	assert.Len(t, stackID, StackIDLength)
+	assert.Contains(t, outputs[0], "\n\terrfmt.newWithDetails() errfmt.go:")
+	assert.Contains(t, outputs[1], KeyStackID+"="+stackID)
+	assert.NotContains(t, outputs[1], "\n\t", "call stack is printed at the first time only")
+	assert.Contains(t, outputs[2], "\n\terrfmt.newWithDetails() errfmt.go:", "other call stack")
+	assert.NotContains(t, outputs[2], KeyStackID+"="+stackID)
+}
+
+func TestStackRegistry_Window(t *testing.T) {
+	registry := NewStackRegistry(time.Minute)
+	frames := []CallStackFrame{{Function: "main.main", Path: "/src/main.go", Line: 1}}
+	ts := time.Unix(1571170345, 0)
+	logger := log.New()
+	first := logger.WithTime(ts)
+
+	stackID, isFirst := registry.Check(first, frames)
+	assert.True(t, isFirst)
+	assert.Equal(t, StackID(frames), stackID)
+	_, isFirst = registry.Check(first, frames)
+	assert.False(t, isFirst, "each Check is an occurrence")
+	_, isFirst = registry.Check(logger.WithTime(ts.Add(time.Second)), frames)
+	assert.False(t, isFirst)
+	_, isFirst = registry.Check(logger.WithTime(ts.Add(time.Minute)), frames)
+	assert.True(t, isFirst, "new window")
+}
+
+func TestStackRegistry_Prune(t *testing.T) {
+	registry := NewStackRegistry(time.Minute)
+	registry.MaxStacks = 3
+	ts := time.Unix(1571170345, 0)
+	logger := log.New()
+	frames := func(line int) []CallStackFrame {
+		return []CallStackFrame{{Function: "main.main", Path: "/src/main.go", Line: line}}
+	}
+
+	for line := 1; line <= 3; line++ {
+		registry.Check(logger.WithTime(ts), frames(line))
+	}
+	assert.Equal(t, 3, registry.Len())
+	_, isFirst := registry.Check(logger.WithTime(ts.Add(2*time.Minute)), frames(4))
+	assert.True(t, isFirst)
+	assert.Equal(t, 1, registry.Len(), "expired call stacks are removed")
+
+	for line := 5; line <= 7; line++ {
+		registry.Check(logger.WithTime(ts.Add(2*time.Minute)), frames(line))
+	}
+	assert.Equal(t, 1, registry.Len(), "full registry is cleared")
+}
+
+func TestStackRegistry_OncePerFormat(t *testing.T) {
+	registry := NewStackRegistry(0)
+	formatter := NewAdvancedCEFFormatter(FlagCallStackInFields, 0, "Vendor", "Product", "1.0")
+	formatter.StackRegistry = registry
+	entry := log.New().WithError(GenerateDeepErrors())
+
+	first, err := formatter.Format(entry)
+	assert.Nil(t, err)
+	assert.Contains(t, string(first), "errfmt.newWithDetails() errfmt.go:", "PrepareFields and GetCallStack")
+	assert.Contains(t, string(first), KeyStackID+"=")
+	_, checked := getStackCheck(entry)
+	assert.False(t, checked, "entry of the caller is not modified")
+
+	second, err := formatter.Format(entry)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(second), "errfmt.newWithDetails()", "same entry, repeated Format")
+	assert.Contains(t, string(second), KeyStackID+"=")
+}
+
+func TestStackRegistry_JSONSuppressed(t *testing.T) {
+	loggerMock := newJSONLoggerMock(FlagCallStackInFields, 0)
+	GetAdvancedFormatter(loggerMock.Formatter).StackRegistry = NewStackRegistry(0)
+
+	outputs := []string{}
+	for i := 0; i < 2; i++ {
+		loggerMock.outBuf.Reset()
+		loggerMock.WithError(GenerateDeepErrors()).Error("USER MSG")
+		outputs = append(outputs, loggerMock.outBuf.String())
+	}
+
+	assert.Contains(t, outputs[0], `"`+KeyCallStack+`":`)
+	assert.Contains(t, outputs[1], `"`+KeyStackID+`":`)
+	assert.NotContains(t, outputs[1], `"`+KeyCallStack+`":`, "suppressed call stack is left out")
+}
@@ -0,0 +1,86 @@
+package errfmt
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+CallStackRenderer renders call stack frames to lines
+	See CompactCallStackRenderer, GoPanicCallStackRenderer, JavaCallStackRenderer
+	and AdvancedFormatter.CallStackRenderer
+*/
+type CallStackRenderer struct {
+	// Header is the lines before the frames
+	Header []string
+	// RenderFrame renders a frame (or an elided marker, see CallStackFrame.Elided) to lines
+	RenderFrame func(frame CallStackFrame, trimmer *PackageTrimmer) []string
+}
+
+// Render renders the frames to lines, names are trimmed by the trimmer (if the renderer trims)
+func (r *CallStackRenderer) Render(callStackFrames []CallStackFrame, trimmer *PackageTrimmer) []string {
+	if len(callStackFrames) == 0 {
+		return []string{}
+	}
+
+	callStackLines := make([]string, 0, len(r.Header)+len(callStackFrames))
+	callStackLines = append(callStackLines, r.Header...)
+	for _, frame := range callStackFrames {
+		callStackLines = append(callStackLines, r.RenderFrame(frame, trimmer)...)
+	}
+
+	return callStackLines
+}
+
+// CompactCallStackRenderer renders the compact "func() file:line" lines (default, see PackageTrimmer.FrameString)
+func CompactCallStackRenderer() *CallStackRenderer {
+	return &CallStackRenderer{
+		RenderFrame: func(frame CallStackFrame, trimmer *PackageTrimmer) []string {
+			return []string{trimmer.FrameString(frame)}
+		},
+	}
+}
+
+/*
+GoPanicCallStackRenderer renders the call stack like a Go panic
+	"goroutine 1 [running]:" header, "func(...)" and "\t/full/path.go:123 +0x1f" lines
+	The function entry of the offset is resolved by the frame cache (see FrameCache.Resolve).
+*/
+func GoPanicCallStackRenderer() *CallStackRenderer {
+	return &CallStackRenderer{
+		Header: []string{"goroutine 1 [running]:"},
+		RenderFrame: func(frame CallStackFrame, _ *PackageTrimmer) []string {
+			if frame.Elided > 0 {
+				return []string{"...additional frames elided..."}
+			}
+
+			location := make([]byte, 0, len(frame.Path)+32)
+			location = append(append(append(location, '\t'), frame.Path...), ':')
+			location = strconv.AppendInt(location, int64(frame.Line), 10)
+			entry := frame.Entry
+			if entry == 0 && frame.PC != 0 {
+				entry = defaultFrameCache.Resolve(frame.PC).Entry
+			}
+			if entry != 0 && frame.PC > entry {
+				location = strconv.AppendUint(append(location, " +0x"...), uint64(frame.PC-entry), 16)
+			}
+
+			return []string{frame.Function + "(...)", string(location)}
+		},
+	}
+}
+
+// JavaCallStackRenderer renders the call stack like a Java stack trace: "at func(file:line)" (full function name)
+func JavaCallStackRenderer() *CallStackRenderer {
+	return &CallStackRenderer{
+		RenderFrame: func(frame CallStackFrame, trimmer *PackageTrimmer) []string {
+			if frame.Elided > 0 {
+				return []string{fmt.Sprintf("... %d more", frame.Elided)}
+			}
+
+			_, fileLine := trimmer.TrimFrame(frame)
+
+			return []string{"at " + frame.Function + "(" + fileLine + ")"}
+		},
+	}
+}
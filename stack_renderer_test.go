@@ -0,0 +1,72 @@
+package errfmt
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestCallStackRenderer_Java(t *testing.T) {
+	funcName := FunctionName()
+	loggerMock := newJSONLoggerMock(FlagCallStackInFields, 2)
+	f := GetAdvancedFormatter(loggerMock.Formatter)
+	f.CallStackRenderer = JavaCallStackRenderer()
+
+	assert.Equal(t, []interface{}{
+		"at github.com/pgillich/errfmt.newWithDetails(errfmt.go:0)",
+		"at github.com/pgillich/errfmt.GenerateDeepErrors(errfmt.go:0)",
+		"at " + funcName + "(stack_renderer_test.go:0)",
+	}, replaceCallLines(toInterfaces(f.GetCallStack(loggerMock.WithError(GenerateDeepErrors())))))
+
+	assert.Equal(t, []string{"at main.main(main.go:3)", "... 2 more"}, JavaCallStackRenderer().Render([]CallStackFrame{
+		{Function: "main.main", Path: "/src/main.go", Line: 3},
+		{Elided: 2},
+	}, DefaultPackageTrimmer()))
+}
+
+func TestCallStackRenderer_GoPanic(t *testing.T) {
+	loggerMock := newTextLoggerMock(FlagCallStackInHTTPProblem, 2)
+	f := GetAdvancedFormatter(loggerMock.Formatter)
+	f.CallStackRenderer = GoPanicCallStackRenderer()
+
+	callStack := BuildHTTPProblem(http.StatusInternalServerError, loggerMock.WithError(GenerateDeepErrors())).CallStack
+	assert.Len(t, callStack, 7)
+	assert.Equal(t, "goroutine 1 [running]:", callStack[0])
+	assert.Equal(t, "github.com/pgillich/errfmt.newWithDetails(...)", callStack[1])
+	assert.Regexp(t, `^\t/.*/errfmt.go:\d+ \+0x[0-9a-f]+$`, callStack[2])
+	assert.Equal(t, FunctionName()+"(...)", callStack[5])
+
+	assert.Empty(t, GoPanicCallStackRenderer().Render([]CallStackFrame{}, DefaultPackageTrimmer()))
+	assert.Equal(t, []string{"goroutine 1 [running]:", "main.main(...)", "\t/src/main.go:3", "...additional frames elided..."},
+		GoPanicCallStackRenderer().Render([]CallStackFrame{
+			{Function: "main.main", Path: "/src/main.go", Line: 3},
+			{Elided: 2},
+		}, DefaultPackageTrimmer()))
+}
+
+func TestCallStackRenderer_GoPanic_FrameCache(t *testing.T) {
+	f := GetAdvancedFormatter(newTextLoggerMock(FlagCallStackInFields, 0).Formatter)
+	callStackFrames := f.GetCallStackFrames(log.New().WithError(GenerateDeepErrors()))
+	assert.NotZero(t, callStackFrames[0].Entry)
+
+	misses := DefaultFrameCache().Stats().Misses
+	rendered := GoPanicCallStackRenderer().Render(callStackFrames, DefaultPackageTrimmer())
+	assert.Equal(t, misses, DefaultFrameCache().Stats().Misses, "function entries are resolved")
+
+	frame := callStackFrames[0]
+	frame.Entry = 0
+	assert.Equal(t, rendered[:3], GoPanicCallStackRenderer().Render([]CallStackFrame{frame}, DefaultPackageTrimmer()),
+		"function entry by PC")
+}
+
+func toInterfaces(items []string) []interface{} {
+	result := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		result = append(result, item)
+	}
+
+	return result
+}
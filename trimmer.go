@@ -0,0 +1,203 @@
+package errfmt
+
+import (
+	"go/build"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultPackageTrimmer is the trim configuration of formatters without own PackageTrimmer
+var defaultPackageTrimmer = struct { // nolint:gochecknoglobals
+	once    sync.Once
+	trimmer *PackageTrimmer
+}{}
+
+/*
+PackageTrimmer is a concurrency-safe trim configuration of function names and file paths
+	Function names are trimmed by the shortest matching package prefix.
+	Functions of the prefixes are application functions (see IsApplicationFunction).
+	The prefixes are copy-on-write: readers (logging goroutines) are lock-free,
+	so prefixes can be added after loggers are started.
+	See AdvancedFormatter.PackageTrimmer and DefaultPackageTrimmer.
+*/
+type PackageTrimmer struct {
+	generationCounter uint64 // atomic, first for 64-bit alignment, incremented by AddPrefix (see FrameCache)
+
+	// FilePaths prints file paths relative to module roots, instead of file names (see ModuleRelativePath)
+	FilePaths bool
+
+	mu       sync.Mutex   // serializes the writers
+	prefixes atomic.Value // []string, shortest first
+}
+
+// NewPackageTrimmer makes a new PackageTrimmer with package prefixes (for example MainModulePath())
+func NewPackageTrimmer(prefixes ...string) *PackageTrimmer {
+	trimmer := &PackageTrimmer{}
+	for _, prefix := range prefixes {
+		trimmer.AddPrefix(prefix)
+	}
+
+	return trimmer
+}
+
+/*
+DefaultPackageTrimmer returns the default PackageTrimmer
+	It's initialized by the main module path (see MainModulePath),
+	further prefixes can be added by AddSkipPackageFromStackTrace.
+*/
+func DefaultPackageTrimmer() *PackageTrimmer {
+	defaultPackageTrimmer.once.Do(func() {
+		defaultPackageTrimmer.trimmer = NewPackageTrimmer()
+		if mainModulePath := MainModulePath(); mainModulePath != "" {
+			defaultPackageTrimmer.trimmer.AddPrefix(mainModulePath)
+		}
+	})
+
+	return defaultPackageTrimmer.trimmer
+}
+
+// MainModulePath returns the main module path from runtime/debug.ReadBuildInfo (empty, if unknown)
+func MainModulePath() string {
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		return buildInfo.Main.Path
+	}
+
+	return ""
+}
+
+// AddPrefix adds a package prefix
+func (t *PackageTrimmer) AddPrefix(prefix string) {
+	if prefix == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	known := t.loadPrefixes()
+	for _, knownPrefix := range known {
+		if knownPrefix == prefix {
+			return
+		}
+	}
+	prefixes := append(make([]string, 0, len(known)+1), known...)
+	prefixes = append(prefixes, prefix)
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) < len(prefixes[j])
+	})
+	t.prefixes.Store(prefixes)
+	atomic.AddUint64(&t.generationCounter, 1)
+}
+
+// Prefixes returns a copy of the package prefixes (shortest first)
+func (t *PackageTrimmer) Prefixes() []string {
+	return append([]string{}, t.loadPrefixes()...)
+}
+
+// TrimFunctionName trims the shortest matching package prefix
+func (t *PackageTrimmer) TrimFunctionName(functionName string) string {
+	if prefix := t.matchPrefix(functionName); prefix != "" {
+		return functionName[len(prefix)+1:]
+	}
+
+	return functionName
+}
+
+// IsApplicationFunction returns true, if the function is in a package of the prefixes
+func (t *PackageTrimmer) IsApplicationFunction(functionName string) bool {
+	return t.matchPrefix(functionName) != ""
+}
+
+// TrimFilePath returns the file name, or the path relative to the module root, if FilePaths is set
+func (t *PackageTrimmer) TrimFilePath(functionName string, filePath string) string {
+	if t.FilePaths {
+		return ModuleRelativePath(functionName, filePath)
+	}
+
+	return path.Base(filepath.ToSlash(filePath))
+}
+
+// FrameString returns the compact call stack line: trimmed function name, file and line (cached, see FrameCache)
+func (t *PackageTrimmer) FrameString(frame CallStackFrame) string {
+	if frame.Elided > 0 {
+		return frame.String()
+	}
+
+	return defaultFrameCache.trim(frame.PC, frame.Function, frame.Path, frame.Line, t).frameString
+}
+
+// TrimFrame returns the trimmed function name and "file:line" of the frame (cached, see FrameCache)
+func (t *PackageTrimmer) TrimFrame(frame CallStackFrame) (string, string) {
+	trimmed := defaultFrameCache.trim(frame.PC, frame.Function, frame.Path, frame.Line, t)
+
+	return trimmed.trimmedFunction, trimmed.fileLine
+}
+
+// CallerPrettyfier trims the function name and the file path, fits to TextFormatter.CallerPrettyfier (cached)
+func (t *PackageTrimmer) CallerPrettyfier(frame *runtime.Frame) (string, string) {
+	trimmed := defaultFrameCache.trim(frame.PC, frame.Function, frame.File, frame.Line, t)
+
+	return trimmed.trimmedFunction, trimmed.fileLine
+}
+
+// matchPrefix returns the shortest matching prefix (empty, if not found)
+func (t *PackageTrimmer) matchPrefix(functionName string) string {
+	for _, prefix := range t.loadPrefixes() {
+		if strings.HasPrefix(functionName, prefix) && len(functionName) > len(prefix) {
+			if next := functionName[len(prefix)]; next == '.' || next == '/' {
+				return prefix
+			}
+		}
+	}
+
+	return ""
+}
+
+// generation returns the version of the prefixes
+func (t *PackageTrimmer) generation() uint64 {
+	return atomic.LoadUint64(&t.generationCounter)
+}
+
+// loadPrefixes returns the current (immutable) prefixes
+func (t *PackageTrimmer) loadPrefixes() []string {
+	prefixes, _ := t.prefixes.Load().([]string)
+
+	return prefixes
+}
+
+/*
+ModuleRelativePath returns the file path relative to the module root
+	Module cache: module@version/path, GOROOT and GOPATH: relative to the src directory,
+	other modules (see RegisterSourceModule): the package path in the module.
+	Returns the file name, if the module is unknown.
+*/
+func ModuleRelativePath(functionName string, filePath string) string {
+	filePath = filepath.ToSlash(filePath)
+	if i := strings.LastIndex(filePath, "/pkg/mod/"); i >= 0 {
+		return filePath[i+len("/pkg/mod/"):]
+	}
+
+	srcRoots := []string{filepath.ToSlash(runtime.GOROOT())}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		srcRoots = append(srcRoots, filepath.ToSlash(gopath))
+	}
+	for _, srcRoot := range srcRoots {
+		if srcRoot != "" && strings.HasPrefix(filePath, srcRoot+"/src/") {
+			return strings.TrimPrefix(filePath, srcRoot+"/src/")
+		}
+	}
+
+	fileName := path.Base(filePath)
+	pkgPath := packagePathOfFunction(functionName)
+	if modulePath, _, ok := findSourceModule(pkgPath); ok && pkgPath != modulePath {
+		return strings.TrimPrefix(pkgPath, modulePath+"/") + "/" + fileName
+	}
+
+	return fileName
+}
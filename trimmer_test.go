@@ -0,0 +1,100 @@
+package errfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestPackageTrimmer_TrimFunctionName(t *testing.T) {
+	trimmer := NewPackageTrimmer("example.com/mod/sub", "example.com/mod", "example.com/mod")
+
+	assert.Equal(t, []string{"example.com/mod", "example.com/mod/sub"}, trimmer.Prefixes())
+	assert.Equal(t, "sub.(*T).Method", trimmer.TrimFunctionName("example.com/mod/sub.(*T).Method"))
+	assert.Equal(t, "main", trimmer.TrimFunctionName("example.com/mod.main"))
+	assert.Equal(t, "example.com/module.main", trimmer.TrimFunctionName("example.com/module.main"))
+	assert.True(t, trimmer.IsApplicationFunction("example.com/mod/sub.F"))
+	assert.False(t, trimmer.IsApplicationFunction("example.com/module.F"))
+	assert.False(t, trimmer.IsApplicationFunction("runtime.goexit"))
+
+	assert.Equal(t, "main() main.go:3",
+		trimmer.FrameString(CallStackFrame{Function: "example.com/mod.main", Path: "/src/main.go", Line: 3}))
+	assert.Equal(t, "... 2 frames elided", trimmer.FrameString(CallStackFrame{Elided: 2}))
+}
+
+func TestModuleRelativePath(t *testing.T) {
+	RegisterSourceModule("example.com/trim", "v0.1.0")
+
+	assert.Equal(t, "github.com/x/y@v1.0.0/z/file.go",
+		ModuleRelativePath("github.com/x/y/z.F", "/home/user/go/pkg/mod/github.com/x/y@v1.0.0/z/file.go"))
+	assert.Equal(t, "net/http/server.go",
+		ModuleRelativePath("net/http.(*conn).serve", runtime.GOROOT()+"/src/net/http/server.go"))
+	assert.Equal(t, "sub/pkg/file.go", ModuleRelativePath("example.com/trim/sub/pkg.F", "/work/trim/sub/pkg/file.go"))
+	assert.Equal(t, "file.go", ModuleRelativePath("unknown.org/pkg.F", "/work/pkg/file.go"))
+
+	trimmer := NewPackageTrimmer("example.com/trim")
+	trimmer.FilePaths = true
+	assert.Equal(t, "sub/pkg.F() sub/pkg/file.go:7",
+		trimmer.FrameString(CallStackFrame{Function: "example.com/trim/sub/pkg.F", Path: "/work/trim/sub/pkg/file.go", Line: 7}))
+}
+
+func TestDefaultPackageTrimmer_MainModule(t *testing.T) {
+	assert.Equal(t, "github.com/pgillich/errfmt", MainModulePath())
+	assert.Contains(t, DefaultPackageTrimmer().Prefixes(), MainModulePath())
+}
+
+func TestPackageTrimmer_PerFormatter(t *testing.T) {
+	loggerMock := newJSONLoggerMock(FlagCallStackInFields, 2)
+	GetAdvancedFormatter(loggerMock.Formatter).PackageTrimmer = NewPackageTrimmer(MainModulePath())
+
+	loggerMock.WithError(GenerateDeepErrors()).Log(log.ErrorLevel, "USER MSG")
+	message := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(loggerMock.outBuf.Bytes(), &message))
+	assert.Equal(t, "TestPackageTrimmer_PerFormatter", message[log.FieldKeyFunc])
+	callStack, ok := message[KeyCallStack].([]interface{})
+	assert.True(t, ok, KeyCallStack)
+	assert.Len(t, callStack, 3)
+	assert.Regexp(t, `^newWithDetails\(\) errfmt.go:\d+$`, callStack[0])
+}
+
+func TestPackageTrimmer_ConcurrentRegistration(t *testing.T) {
+	jsonLoggerMock := newJSONLoggerMock(FlagCallStackInFields, 2)
+	GetAdvancedFormatter(jsonLoggerMock.Formatter).SourceURLTemplate = testSourceURLTemplate
+	textLoggerMock := newTextLoggerMock(FlagCallStackInFields|FlagCallStackAppOnly, 2)
+	trimmer := NewPackageTrimmer()
+	GetAdvancedFormatter(textLoggerMock.Formatter).PackageTrimmer = trimmer
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				AddSkipPackageFromStackTrace(fmt.Sprintf("example.com/race%d/pkg%d", g, i))
+				RegisterSourceModule(fmt.Sprintf("example.com/race%d/mod%d", g, i), "v0.0.1")
+				trimmer.AddPrefix(fmt.Sprintf("example.com/race%d/pkg%d", g, i))
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				jsonLoggerMock.WithError(GenerateDeepErrors()).Log(log.ErrorLevel, "USER MSG")
+				textLoggerMock.WithError(GenerateDeepErrors()).Log(log.ErrorLevel, "USER MSG")
+				TrimModuleNamePrefix(FunctionName())
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, trimmer.Prefixes(), 8*20)
+	assert.Contains(t, DefaultPackageTrimmer().Prefixes(), "example.com/race7/pkg19")
+	_, version, ok := findSourceModule("example.com/race7/mod19/sub")
+	assert.True(t, ok)
+	assert.Equal(t, "v0.0.1", version)
+}
@@ -0,0 +1,315 @@
+package errfmt
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"emperror.dev/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AsyncDropNewest drops the written entry, if the queue is full
+	AsyncDropNewest = 0
+	// AsyncDropOldest drops the oldest queued entry, if the queue is full
+	AsyncDropOldest = 1
+	// AsyncBlock blocks the writer, until the queue has free space
+	AsyncBlock = 2
+
+	// AsyncQueueSize is the default number of queued entries
+	AsyncQueueSize = 1024
+)
+
+// ErrAsyncWriterClosed is returned by AsyncWriter.Write after Close
+var ErrAsyncWriterClosed = errors.NewPlain("async writer is closed") // nolint:gochecknoglobals
+
+/*
+AsyncWriter is an io.Writer, writing formatted entries to Out in the background
+	Each Write copies the entry into a bounded ring buffer, so logging does not wait for slow
+	outputs (stdout, syslog). If the queue is full, DropPolicy is applied
+	(AsyncDropNewest, AsyncDropOldest or AsyncBlock), dropped entries are counted (see Stats).
+	Panic and fatal entries, formatted by AsyncWriterFormatter, are written synchronously
+	(never dropped), because the process may stop after them. See SetAsyncWriter and ExitFunc.
+*/
+type AsyncWriter struct {
+	written uint64 // atomic, first for 64-bit alignment
+	dropped uint64 // atomic
+	failed  uint64 // atomic
+
+	// DropPolicy is one of AsyncDropNewest, AsyncDropOldest, AsyncBlock
+	DropPolicy int
+
+	out        io.Writer
+	mu         sync.Mutex
+	changed    *sync.Cond
+	slots      [][]byte
+	head       int
+	headSeq    uint64 // sequence number of the entry at head (entries are numbered by Write)
+	count      int
+	writing    bool
+	writingSeq uint64 // sequence number of the entry written to Out (if writing)
+	closed     bool
+	lastErr    error
+	done       chan struct{}
+	// synchronous are the formatted panic and fatal entries (by first byte, see AsyncWriterFormatter)
+	synchronous map[*byte]*AsyncWriterFormatter
+}
+
+// AsyncWriterStats are the counters of AsyncWriter
+type AsyncWriterStats struct {
+	// Queued is the number of entries in the queue
+	Queued int
+	// Written is the number of entries written to Out
+	Written uint64
+	// Dropped is the number of entries dropped by DropPolicy
+	Dropped uint64
+	// Failed is the number of failed writes to Out
+	Failed uint64
+}
+
+// NewAsyncWriter makes a new AsyncWriter to out and starts the background writer (size is AsyncQueueSize, if not positive)
+func NewAsyncWriter(out io.Writer, size int, dropPolicy int) *AsyncWriter {
+	if size <= 0 {
+		size = AsyncQueueSize
+	}
+
+	w := &AsyncWriter{
+		DropPolicy:  dropPolicy,
+		out:         out,
+		slots:       make([][]byte, size),
+		done:        make(chan struct{}),
+		synchronous: map[*byte]*AsyncWriterFormatter{},
+	}
+	w.changed = sync.NewCond(&w.mu)
+	go w.run()
+
+	return w
+}
+
+/*
+SetAsyncWriter replaces the output of the logger to a new AsyncWriter
+	Entries are flushed before exit (see ExitFunc), panic and fatal entries are written synchronously.
+*/
+func SetAsyncWriter(logger *log.Logger, size int, dropPolicy int) *AsyncWriter {
+	w := NewAsyncWriter(logger.Out, size, dropPolicy)
+	logger.SetOutput(w)
+	logger.ExitFunc = w.ExitFunc(logger.ExitFunc)
+	logger.SetFormatter(&AsyncWriterFormatter{Formatter: logger.Formatter, Writer: w})
+
+	return w
+}
+
+// Write implements io.Writer interface, the entry is copied into the queue
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	entry := append(make([]byte, 0, len(p)), p...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	synchronous := w.takeSynchronous(p)
+	if w.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+
+	if w.count == len(w.slots) {
+		switch {
+		case synchronous || w.DropPolicy == AsyncBlock:
+			for w.count == len(w.slots) && !w.closed {
+				w.changed.Wait()
+			}
+			if w.closed {
+				return 0, ErrAsyncWriterClosed
+			}
+		case w.DropPolicy == AsyncDropOldest:
+			w.slots[w.head] = nil
+			w.head = (w.head + 1) % len(w.slots)
+			w.headSeq++
+			w.count--
+			atomic.AddUint64(&w.dropped, 1)
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+			return len(p), nil
+		}
+	}
+
+	seq := w.headSeq + uint64(w.count)
+	w.slots[(w.head+w.count)%len(w.slots)] = entry
+	w.count++
+	w.changed.Broadcast()
+
+	if synchronous {
+		w.waitWritten(seq)
+	}
+
+	return len(p), nil
+}
+
+// Flush waits, until the entries queued before are written to Out (later entries are not waited for)
+func (w *AsyncWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if next := w.headSeq + uint64(w.count); next > 0 {
+		w.waitWritten(next - 1)
+	}
+}
+
+// Close writes the queued entries and stops the background writer, returns the last write error
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.changed.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.lastErr
+}
+
+// Stats returns the counters
+func (w *AsyncWriter) Stats() AsyncWriterStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return AsyncWriterStats{
+		Queued:  w.count,
+		Written: atomic.LoadUint64(&w.written),
+		Dropped: atomic.LoadUint64(&w.dropped),
+		Failed:  atomic.LoadUint64(&w.failed),
+	}
+}
+
+// ExitFunc wraps the exit function of logrus.Logger (os.Exit, if nil): queued entries are flushed before exit
+func (w *AsyncWriter) ExitFunc(exit func(int)) func(int) {
+	if exit == nil {
+		exit = os.Exit
+	}
+
+	return func(code int) {
+		w.Flush()
+		exit(code)
+	}
+}
+
+// markSynchronous marks the formatted entry of the formatter to be written synchronously by the next Write of it
+func (w *AsyncWriter) markSynchronous(f *AsyncWriterFormatter, p []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.synchronous[&p[0]] = f
+}
+
+// unmarkSynchronous clears the mark of the formatter, if the marked entry was not written
+func (w *AsyncWriter) unmarkSynchronous(f *AsyncWriterFormatter, key *byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.synchronous[key] == f {
+		delete(w.synchronous, key)
+	}
+}
+
+// takeSynchronous returns true (and clears the mark), if the entry was marked by markSynchronous, must be called with locked mu
+func (w *AsyncWriter) takeSynchronous(p []byte) bool {
+	key := &p[0]
+	if _, ok := w.synchronous[key]; !ok {
+		return false
+	}
+	delete(w.synchronous, key)
+
+	return true
+}
+
+/*
+AsyncWriterFormatter is a logrus.Formatter wrapper, marking panic and fatal entries for AsyncWriter
+	logrus formats and writes an entry under the same logger lock, so the marked output of Format
+	is identified by the next Write of the same bytes: it's written synchronously, never dropped.
+	If the marked entry is not written (for example, the output was changed), the mark is cleared
+	by the next Format, so a reused buffer is not taken as marked. See SetAsyncWriter.
+*/
+type AsyncWriterFormatter struct {
+	log.Formatter
+	Writer *AsyncWriter
+
+	mu     sync.Mutex
+	marked *byte
+}
+
+// Format implements logrus.Formatter interface
+func (f *AsyncWriterFormatter) Format(entry *log.Entry) ([]byte, error) {
+	serialized, err := f.Formatter.Format(entry)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.marked != nil {
+		f.Writer.unmarkSynchronous(f, f.marked)
+		f.marked = nil
+	}
+	if err == nil && len(serialized) > 0 && entry.Level <= log.FatalLevel {
+		f.marked = &serialized[0]
+		f.Writer.markSynchronous(f, serialized)
+	}
+
+	return serialized, err
+}
+
+// waitWritten waits, until the entry of the sequence number is written to Out (or dropped), must be called with locked mu
+func (w *AsyncWriter) waitWritten(seq uint64) {
+	for seq >= w.headSeq || (w.writing && w.writingSeq == seq) {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		w.changed.Wait()
+	}
+}
+
+// run writes the queued entries to Out, until Close
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		for w.count == 0 && !w.closed {
+			w.changed.Wait()
+		}
+		if w.count == 0 {
+			w.changed.Broadcast()
+			return
+		}
+
+		entry := w.slots[w.head]
+		w.slots[w.head] = nil
+		w.head = (w.head + 1) % len(w.slots)
+		w.writingSeq = w.headSeq
+		w.headSeq++
+		w.count--
+		w.writing = true
+		w.changed.Broadcast()
+		w.mu.Unlock()
+
+		_, err := w.out.Write(entry)
+
+		w.mu.Lock()
+		w.writing = false
+		if err != nil {
+			w.lastErr = err
+			atomic.AddUint64(&w.failed, 1)
+		} else {
+			atomic.AddUint64(&w.written, 1)
+		}
+		w.changed.Broadcast()
+	}
+}
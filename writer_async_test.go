@@ -0,0 +1,231 @@
+package errfmt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// blockingWriter blocks the writes, until released
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{
+		started: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.started <- struct{}{}
+	<-w.release
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.String()
+}
+
+// fillAsyncWriter writes "1" (blocked in Out), then "2" and "3" into the full queue
+func fillAsyncWriter(t *testing.T, dropPolicy int) (*AsyncWriter, *blockingWriter) {
+	out := newBlockingWriter()
+	w := NewAsyncWriter(out, 2, dropPolicy)
+	for _, entry := range []string{"1", "2", "3"} {
+		n, err := w.Write([]byte(entry))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, n)
+		if entry == "1" {
+			<-out.started
+		}
+	}
+	assert.Equal(t, AsyncWriterStats{Queued: 2}, w.Stats())
+
+	return w, out
+}
+
+func TestAsyncWriter_DropNewest(t *testing.T) {
+	w, out := fillAsyncWriter(t, AsyncDropNewest)
+	_, err := w.Write([]byte("4"))
+	assert.Nil(t, err)
+
+	close(out.release)
+	w.Flush()
+	assert.Equal(t, "123", out.String())
+	assert.Equal(t, AsyncWriterStats{Written: 3, Dropped: 1}, w.Stats())
+	assert.Nil(t, w.Close())
+}
+
+func TestAsyncWriter_DropOldest(t *testing.T) {
+	w, out := fillAsyncWriter(t, AsyncDropOldest)
+	_, err := w.Write([]byte("4"))
+	assert.Nil(t, err)
+
+	close(out.release)
+	assert.Nil(t, w.Close())
+	assert.Equal(t, "134", out.String())
+	assert.Equal(t, AsyncWriterStats{Written: 3, Dropped: 1}, w.Stats())
+
+	_, err = w.Write([]byte("5"))
+	assert.Equal(t, ErrAsyncWriterClosed, err)
+}
+
+func TestAsyncWriter_Block(t *testing.T) {
+	w, out := fillAsyncWriter(t, AsyncBlock)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		_, err := w.Write([]byte("4"))
+		assert.Nil(t, err)
+	}()
+
+	select {
+	case <-written:
+		t.Error("Write is not blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(out.release)
+	<-written
+	w.Flush()
+	assert.Equal(t, "1234", out.String())
+	assert.Equal(t, AsyncWriterStats{Written: 4}, w.Stats())
+	assert.Nil(t, w.Close())
+}
+
+func TestAsyncWriter_FatalPanic(t *testing.T) {
+	loggerMock := newJSONLoggerMock(FlagNone, 0)
+	w := SetAsyncWriter(loggerMock.Logger, 0, AsyncDropNewest)
+	defer w.Close() // nolint:errcheck
+
+	loggerMock.Info("INFO MSG")
+	loggerMock.Fatal("FATAL MSG")
+	assert.Equal(t, 1, loggerMock.exitCode)
+	assert.Contains(t, loggerMock.outBuf.String(), "INFO MSG")
+	assert.Contains(t, loggerMock.outBuf.String(), "FATAL MSG")
+
+	assert.Panics(t, func() { loggerMock.Panic("PANIC MSG") })
+	assert.Contains(t, loggerMock.outBuf.String(), "PANIC MSG")
+	assert.Equal(t, AsyncWriterStats{Written: 3}, w.Stats())
+}
+
+func TestAsyncWriter_NotWrittenFatal(t *testing.T) {
+	loggerMock := newJSONLoggerMock(FlagNone, 0)
+	w := SetAsyncWriter(loggerMock.Logger, 0, AsyncDropNewest)
+	defer w.Close() // nolint:errcheck
+
+	other := new(bytes.Buffer)
+	loggerMock.SetOutput(other)
+	loggerMock.Fatal("FATAL MSG")
+	assert.Contains(t, other.String(), "FATAL MSG")
+
+	loggerMock.SetOutput(w)
+	loggerMock.Info("INFO MSG")
+	w.mu.Lock()
+	assert.Empty(t, w.synchronous, "mark of the not written entry is cleared")
+	w.mu.Unlock()
+}
+
+func TestAsyncWriter_Concurrent(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewAsyncWriter(out, 16, AsyncBlock)
+	logger := log.New()
+	logger.SetOutput(w)
+	logger.SetFormatter(NewAdvancedTextFormatter(FlagCallStackInFields, 2))
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				logger.WithError(GenerateDeepErrors()).Error(fmt.Sprintf("MSG %d %d", g, i))
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.Nil(t, w.Close())
+
+	assert.Equal(t, 8*50, strings.Count(out.String(), "\n"))
+	assert.Equal(t, AsyncWriterStats{Written: 8 * 50}, w.Stats())
+}
+
+// slowWriter writes into a buffer with delay
+type slowWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(50 * time.Microsecond)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.String()
+}
+
+func TestAsyncWriter_FatalUnderFlood(t *testing.T) {
+	for _, dropPolicy := range []int{AsyncDropNewest, AsyncDropOldest} {
+		out := &slowWriter{}
+		logger := NewTextLogger(log.InfoLevel, FlagNone, 0)
+		logger.SetOutput(out)
+		exits := 0
+		logger.ExitFunc = func(int) { exits++ }
+		w := SetAsyncWriter(logger, 2, dropPolicy)
+
+		stop := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						logger.Info("FLOOD MSG")
+					}
+				}
+			}()
+		}
+		for i := 0; i < 20; i++ {
+			logger.Fatal(fmt.Sprintf("FATAL MSG %d", i))
+		}
+		close(stop)
+		wg.Wait()
+		assert.Nil(t, w.Close())
+
+		assert.Equal(t, 20, exits)
+		assert.Greater(t, w.Stats().Dropped, uint64(0), "flood")
+		for i := 0; i < 20; i++ {
+			assert.Contains(t, out.String(), fmt.Sprintf("msg=\"FATAL MSG %d\"", i))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package errfmt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"io"
+	"net"
+	"sync"
+
+	"emperror.dev/errors"
+)
+
+const (
+	// GELFCompressNone disables compression
+	GELFCompressNone = 0
+	// GELFCompressGzip enables gzip compression
+	GELFCompressGzip = 1
+	// GELFCompressZlib enables zlib compression
+	GELFCompressZlib = 2
+
+	// GELFChunkSize is the default max. UDP datagram size (including chunk header)
+	GELFChunkSize = 1420
+	// GELFMaxChunks is the max. number of chunks of a message
+	GELFMaxChunks = 128
+	// gelfChunkHeaderSize is the size of magic bytes, message ID, sequence number and count
+	gelfChunkHeaderSize = 12
+)
+
+// gelfChunkMagic is the magic bytes of chunked GELF
+var gelfChunkMagic = []byte{0x1e, 0x0f} // nolint:gochecknoglobals
+
+/*
+GELFUDPWriter is an io.Writer, sending GELF messages (for example from AdvancedGELFFormatter) by UDP
+	Each Write sends one message (trailing new line is dropped).
+	Messages bigger than ChunkSize are chunked (max. GELFMaxChunks).
+*/
+type GELFUDPWriter struct {
+	// Compression is one of GELFCompressNone, GELFCompressGzip, GELFCompressZlib
+	Compression int
+	// ChunkSize is the max. UDP datagram size
+	ChunkSize int
+
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// NewGELFUDPWriter makes a new GELFUDPWriter to the address (host:port)
+func NewGELFUDPWriter(address string, compression int) (*GELFUDPWriter, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "cannot open GELF connection", "address", address)
+	}
+
+	return &GELFUDPWriter{
+		Compression: compression,
+		ChunkSize:   GELFChunkSize,
+		conn:        conn,
+	}, nil
+}
+
+// Write implements io.Writer interface
+func (w *GELFUDPWriter) Write(p []byte) (int, error) {
+	message, err := w.compress(bytes.TrimRight(p, "\n"))
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(message) <= w.ChunkSize {
+		if _, err := w.conn.Write(message); err != nil {
+			return 0, errors.WrapIf(err, "cannot send GELF message")
+		}
+		return len(p), nil
+	}
+
+	if err := w.writeChunks(message); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection
+func (w *GELFUDPWriter) Close() error {
+	return w.conn.Close()
+}
+
+func (w *GELFUDPWriter) compress(message []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var compressor io.WriteCloser
+
+	switch w.Compression {
+	case GELFCompressGzip:
+		compressor = gzip.NewWriter(&buf)
+	case GELFCompressZlib:
+		compressor = zlib.NewWriter(&buf)
+	default:
+		return message, nil
+	}
+
+	if _, err := compressor.Write(message); err != nil {
+		return nil, errors.WrapIf(err, "cannot compress GELF message")
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, errors.WrapIf(err, "cannot compress GELF message")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeChunks sends the message in chunks: magic bytes, message ID (8 bytes), sequence number, sequence count, data
+func (w *GELFUDPWriter) writeChunks(message []byte) error {
+	dataSize := w.ChunkSize - gelfChunkHeaderSize
+	if dataSize <= 0 {
+		return errors.NewWithDetails("too small GELF chunk size", "chunkSize", w.ChunkSize)
+	}
+	count := (len(message) + dataSize - 1) / dataSize
+	if count > GELFMaxChunks {
+		return errors.NewWithDetails("too big GELF message", "size", len(message), "chunks", count)
+	}
+
+	messageID := make([]byte, 8)
+	if _, err := rand.Read(messageID); err != nil {
+		return errors.WrapIf(err, "cannot generate GELF message ID")
+	}
+
+	chunk := make([]byte, 0, w.ChunkSize)
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * dataSize
+		if end > len(message) {
+			end = len(message)
+		}
+
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, messageID...)
+		chunk = append(chunk, byte(seq), byte(count))
+		chunk = append(chunk, message[seq*dataSize:end]...)
+		if _, err := w.conn.Write(chunk); err != nil {
+			return errors.WrapWithDetails(err, "cannot send GELF chunk", "seq", seq, "count", count)
+		}
+	}
+
+	return nil
+}
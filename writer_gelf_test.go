@@ -0,0 +1,92 @@
+package errfmt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readGELFMessage reads and reassembles one (chunked) GELF message
+func readGELFMessage(t *testing.T, server net.PacketConn) []byte {
+	chunks := map[byte][]byte{}
+	buf := make([]byte, 65536)
+	for {
+		assert.Nil(t, server.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := server.ReadFrom(buf)
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		datagram := append([]byte{}, buf[:n]...)
+		if !bytes.HasPrefix(datagram, gelfChunkMagic) {
+			return datagram
+		}
+
+		chunks[datagram[10]] = datagram[gelfChunkHeaderSize:]
+		if count := int(datagram[11]); len(chunks) == count {
+			message := []byte{}
+			for seq := 0; seq < count; seq++ {
+				message = append(message, chunks[byte(seq)]...)
+			}
+			return message
+		}
+	}
+}
+
+func decompressGELF(t *testing.T, message []byte) string {
+	var reader io.Reader = bytes.NewReader(message)
+	var err error
+	switch {
+	case bytes.HasPrefix(message, []byte{0x1f, 0x8b}):
+		reader, err = gzip.NewReader(reader)
+	case message[0] == 0x78:
+		reader, err = zlib.NewReader(reader)
+	}
+	assert.Nil(t, err)
+	text, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+
+	return string(text)
+}
+
+func TestGELFUDPWriter(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer server.Close() // nolint:errcheck
+
+	for _, compression := range []int{GELFCompressNone, GELFCompressGzip, GELFCompressZlib} {
+		writer, err := NewGELFUDPWriter(server.LocalAddr().String(), compression)
+		assert.Nil(t, err)
+
+		short := `{"short_message":"short"}`
+		n, err := writer.Write([]byte(short + "\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, len(short)+1, n)
+		assert.Equal(t, short, decompressGELF(t, readGELFMessage(t, server)))
+
+		writer.ChunkSize = 100
+		long := `{"short_message":"` + strings.Repeat("0123456789", 200) + `"}`
+		_, err = writer.Write([]byte(long))
+		assert.Nil(t, err)
+		assert.Equal(t, long, decompressGELF(t, readGELFMessage(t, server)))
+
+		assert.Nil(t, writer.Close())
+	}
+}
+
+func TestGELFUDPWriter_TooBig(t *testing.T) {
+	writer, err := NewGELFUDPWriter("127.0.0.1:9", GELFCompressNone)
+	assert.Nil(t, err)
+	defer writer.Close() // nolint:errcheck
+
+	writer.ChunkSize = gelfChunkHeaderSize + 1
+	_, err = writer.Write(make([]byte, GELFMaxChunks+1))
+	assert.NotNil(t, err)
+}